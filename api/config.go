@@ -0,0 +1,9 @@
+package api
+
+// Config holds the configuration of the admin HTTP API
+type Config struct {
+	Enabled bool
+	Host    string
+	Port    int
+	Token   string
+}
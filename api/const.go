@@ -0,0 +1,10 @@
+package api
+
+// EndpointSessions is the path for listing (GET), starting (POST) and stopping (DELETE) sessions
+const EndpointSessions string = "/api/sessions"
+
+// EndpointQR is the path for getting the QR code of a session as PNG (GET)
+const EndpointQR string = "/api/sessions/qr"
+
+// ParamTopic is the query parameter selecting the topic of a session
+const ParamTopic string = "topic"
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+	"rsc.io/qr"
+)
+
+// SessionManager manages the hauk sessions of all topics
+type SessionManager interface {
+	Sessions() map[string]store.Entry
+	ShareURL(topic string) (string, bool)
+	StartSession(topic string) (hauk.Session, error)
+	StopSession(topic string) error
+}
+
+// Server is the admin HTTP API for managing sessions
+type Server struct {
+	config   Config
+	sessions SessionManager
+	mux      *http.ServeMux
+}
+
+type sessionResponse struct {
+	Topic   string    `json:"topic"`
+	URL     string    `json:"url"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates a new instance of the admin HTTP API
+func New(config Config, sessions SessionManager) *Server {
+	server := &Server{config: config, sessions: sessions, mux: http.NewServeMux()}
+	server.mux.HandleFunc(EndpointSessions, server.handleSessions)
+	server.mux.HandleFunc(EndpointQR, server.handleQR)
+	return server
+}
+
+// ListenAndServe starts serving the API, it blocks until the server fails
+func (t *Server) ListenAndServe() error {
+	address := fmt.Sprintf("%s:%d", t.config.Host, t.config.Port)
+	log.Printf("Serving API on %s\n", address)
+	return http.ListenAndServe(address, t)
+}
+
+// ServeHTTP checks the token and dispatches the request to the endpoints
+func (t *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if !t.isAuthorized(request) {
+		writeJSON(writer, http.StatusUnauthorized, errorResponse{Error: "Unauthorized"})
+		return
+	}
+	t.mux.ServeHTTP(writer, request)
+}
+
+func (t *Server) isAuthorized(request *http.Request) bool {
+	if t.config.Token == "" {
+		return false
+	}
+	authorization := request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(t.config.Token)) == 1
+}
+
+func (t *Server) handleSessions(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		t.listSessions(writer)
+	case http.MethodPost:
+		t.startSession(writer, request)
+	case http.MethodDelete:
+		t.stopSession(writer, request)
+	default:
+		writeJSON(writer, http.StatusMethodNotAllowed, errorResponse{Error: "Method not allowed"})
+	}
+}
+
+func (t *Server) listSessions(writer http.ResponseWriter) {
+	sessions := []sessionResponse{}
+	for topic, entry := range t.sessions.Sessions() {
+		sessions = append(sessions, t.createSessionResponse(topic, entry))
+	}
+	writeJSON(writer, http.StatusOK, sessions)
+}
+
+func (t *Server) startSession(writer http.ResponseWriter, request *http.Request) {
+	topic, ok := getTopic(writer, request)
+	if !ok {
+		return
+	}
+	if _, err := t.sessions.StartSession(topic); err != nil {
+		writeJSON(writer, http.StatusBadGateway, errorResponse{Error: err.Error()})
+		return
+	}
+	entry := t.sessions.Sessions()[topic]
+	writeJSON(writer, http.StatusCreated, t.createSessionResponse(topic, entry))
+}
+
+func (t *Server) stopSession(writer http.ResponseWriter, request *http.Request) {
+	topic, ok := getTopic(writer, request)
+	if !ok {
+		return
+	}
+	if _, sessionExists := t.sessions.ShareURL(topic); !sessionExists {
+		writeJSON(writer, http.StatusNotFound, errorResponse{Error: "Session not found"})
+		return
+	}
+	if err := t.sessions.StopSession(topic); err != nil {
+		writeJSON(writer, http.StatusBadGateway, errorResponse{Error: err.Error()})
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (t *Server) handleQR(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeJSON(writer, http.StatusMethodNotAllowed, errorResponse{Error: "Method not allowed"})
+		return
+	}
+	topic, ok := getTopic(writer, request)
+	if !ok {
+		return
+	}
+	shareURL, sessionExists := t.sessions.ShareURL(topic)
+	if !sessionExists {
+		writeJSON(writer, http.StatusNotFound, errorResponse{Error: "Session not found"})
+		return
+	}
+	code, err := qr.Encode(shareURL, qr.L)
+	if err != nil {
+		writeJSON(writer, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writer.Header().Set("Content-Type", "image/png")
+	writer.Write(code.PNG())
+}
+
+func (t *Server) createSessionResponse(topic string, entry store.Entry) sessionResponse {
+	shareURL, _ := t.sessions.ShareURL(topic)
+	return sessionResponse{Topic: topic, URL: shareURL, Created: entry.Created, Expires: entry.Expires}
+}
+
+func getTopic(writer http.ResponseWriter, request *http.Request) (string, bool) {
+	topic := request.URL.Query().Get(ParamTopic)
+	if topic == "" {
+		writeJSON(writer, http.StatusBadRequest, errorResponse{Error: "Parameter topic is missing"})
+		return "", false
+	}
+	return topic, true
+}
+
+func writeJSON(writer http.ResponseWriter, statusCode int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	if err := json.NewEncoder(writer).Encode(value); err != nil {
+		log.Printf("Could not write API response: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+type MockSessionManager struct {
+	mock.Mock
+}
+
+func (t *MockSessionManager) Sessions() map[string]store.Entry {
+	args := t.Called()
+	return args.Get(0).(map[string]store.Entry)
+}
+
+func (t *MockSessionManager) ShareURL(topic string) (string, bool) {
+	args := t.Called(topic)
+	return args.String(0), args.Bool(1)
+}
+
+func (t *MockSessionManager) StartSession(topic string) (hauk.Session, error) {
+	args := t.Called(topic)
+	return args.Get(0).(hauk.Session), args.Error(1)
+}
+
+func (t *MockSessionManager) StopSession(topic string) error {
+	args := t.Called(topic)
+	return args.Error(0)
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	server := New(Config{Token: "token"}, sessions)
+
+	// when
+	response := serve(server, http.MethodGet, EndpointSessions, "wrongtoken")
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	sessions.AssertExpectations(t)
+}
+
+func TestServer_TokenWithoutSchemeIsUnauthorized(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	server := New(Config{Token: "token"}, sessions)
+	request := httptest.NewRequest(http.MethodGet, EndpointSessions, nil)
+	request.Header.Set("Authorization", "token")
+	response := httptest.NewRecorder()
+
+	// when
+	server.ServeHTTP(response, request)
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	sessions.AssertExpectations(t)
+}
+
+func TestServer_EmptyTokenIsUnauthorized(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	server := New(Config{}, sessions)
+
+	// when
+	response := serve(server, http.MethodGet, EndpointSessions, "")
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestServer_ListSessions(t *testing.T) {
+	// given
+	expires := time.Date(2021, 4, 20, 12, 0, 0, 0, time.UTC)
+	sessions := new(MockSessionManager)
+	sessions.On("Sessions").Return(map[string]store.Entry{"owntracks/user/phone": {Session: hauk.Session{SID: "sid", URL: "url"}, Expires: expires}})
+	sessions.On("ShareURL", "owntracks/user/phone").Return("url", true)
+	server := New(Config{Token: "token"}, sessions)
+
+	// when
+	response := serve(server, http.MethodGet, EndpointSessions, "token")
+
+	// then
+	assert.Equal(t, http.StatusOK, response.Code)
+	var body []sessionResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+	assert.Len(t, body, 1)
+	assert.Equal(t, "owntracks/user/phone", body[0].Topic)
+	assert.Equal(t, "url", body[0].URL)
+	assert.True(t, expires.Equal(body[0].Expires))
+}
+
+func TestServer_StartSession(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("StartSession", "owntracks/user/phone").Return(hauk.Session{SID: "sid", URL: "url"}, nil).Once()
+	sessions.On("Sessions").Return(map[string]store.Entry{"owntracks/user/phone": {Session: hauk.Session{SID: "sid", URL: "url"}}})
+	sessions.On("ShareURL", "owntracks/user/phone").Return("url", true)
+	server := New(Config{Token: "token"}, sessions)
+
+	// when
+	response := serve(server, http.MethodPost, EndpointSessions+"?topic=owntracks/user/phone", "token")
+
+	// then
+	assert.Equal(t, http.StatusCreated, response.Code)
+	sessions.AssertExpectations(t)
+}
+
+func TestServer_StopSession(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("ShareURL", "owntracks/user/phone").Return("url", true)
+	sessions.On("StopSession", "owntracks/user/phone").Return(nil).Once()
+	server := New(Config{Token: "token"}, sessions)
+
+	// when
+	response := serve(server, http.MethodDelete, EndpointSessions+"?topic=owntracks/user/phone", "token")
+
+	// then
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	sessions.AssertExpectations(t)
+}
+
+func TestServer_StopSessionNotFound(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("ShareURL", "owntracks/user/phone").Return("", false)
+	server := New(Config{Token: "token"}, sessions)
+
+	// when
+	response := serve(server, http.MethodDelete, EndpointSessions+"?topic=owntracks/user/phone", "token")
+
+	// then
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	sessions.AssertNotCalled(t, "StopSession", "owntracks/user/phone")
+}
+
+func TestServer_QR(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("ShareURL", "owntracks/user/phone").Return("https://hauk.example.com/?ABCD", true)
+	server := New(Config{Token: "token"}, sessions)
+
+	// when
+	response := serve(server, http.MethodGet, EndpointQR+"?topic=owntracks/user/phone", "token")
+
+	// then
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "image/png", response.Header().Get("Content-Type"))
+	assert.Equal(t, "\x89PNG", response.Body.String()[:4])
+}
+
+func serve(server *Server, method string, target string, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
@@ -0,0 +1,11 @@
+package command
+
+// Config holds the configuration of the MQTT command topics
+type Config struct {
+	Enabled bool
+	Prefix  string
+	// Topics are the patterns of the device topics commands are accepted for
+	Topics []string
+	// Password includes the end-to-end password in the links of the responses
+	Password bool
+}
@@ -0,0 +1,19 @@
+package command
+
+// CommandStart creates a new session for the device
+const CommandStart string = "start"
+
+// CommandStop stops the session of the device
+const CommandStop string = "stop"
+
+// CommandExtend replaces the session of the device with one lasting longer
+const CommandExtend string = "extend"
+
+// CommandStatus only reports the session of the device
+const CommandStatus string = "status"
+
+// TopicSuffixCommand is appended to <prefix>/<device topic> for commands
+const TopicSuffixCommand string = "/cmd"
+
+// TopicSuffixResponse is appended to <prefix>/<device topic> for responses
+const TopicSuffixResponse string = "/response"
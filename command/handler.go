@@ -0,0 +1,175 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+// SessionManager manages the hauk sessions of all topics
+type SessionManager interface {
+	Sessions() map[string]store.Entry
+	ShareURL(topic string) (string, bool)
+	StartSession(topic string) (hauk.Session, error)
+	StopSession(topic string) error
+	ExtendSession(topic string, duration time.Duration) (store.Entry, error)
+}
+
+// Client receives commands and publishes responses, e.g. via MQTT
+type Client interface {
+	Subscribe(topic string, handler func(topic string, payload []byte))
+	Publish(topic string, payload []byte, retained bool) error
+}
+
+// Command is sent to <prefix>/<device topic>/cmd, either as JSON or just the command name
+type Command struct {
+	// ID is optional and returned in the response
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	// Duration in seconds, the session is extended by
+	Duration int `json:"duration"`
+}
+
+// Response is published to <prefix>/<device topic>/response after executing a command
+type Response struct {
+	ID      string     `json:"id,omitempty"`
+	Command string     `json:"command"`
+	Topic   string     `json:"topic"`
+	Success bool       `json:"success"`
+	Error   string     `json:"error,omitempty"`
+	Active  bool       `json:"active"`
+	URL     string     `json:"url,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Handler executes commands received on the command topics
+type Handler struct {
+	config   Config
+	sessions SessionManager
+	client   Client
+}
+
+// New creates a new command handler
+func New(config Config, sessions SessionManager, client Client) *Handler {
+	return &Handler{config: config, sessions: sessions, client: client}
+}
+
+// Start subscribes to the command topics of all devices
+func (t *Handler) Start() {
+	topic := t.config.Prefix + "/#"
+	log.Printf("Accepting commands on %s\n", topic)
+	t.client.Subscribe(topic, t.handleMessage)
+}
+
+func (t *Handler) handleMessage(topic string, payload []byte) {
+	// The subscription also matches the responses
+	if !strings.HasSuffix(topic, TopicSuffixCommand) {
+		return
+	}
+	deviceTopic := strings.TrimSuffix(strings.TrimPrefix(topic, t.config.Prefix+"/"), TopicSuffixCommand)
+	if deviceTopic == "" {
+		return
+	}
+	// Otherwise anyone allowed to publish below the prefix could create sessions for arbitrary topics
+	if !t.isDevice(deviceTopic) {
+		metrics.CommandsExecuted.WithLabelValues(commandLabel(""), metrics.ResultFailure).Inc()
+		log.Printf("Rejecting command for %s, which is not a device topic\n", deviceTopic)
+		return
+	}
+
+	response := t.execute(deviceTopic, payload)
+	if response.Success {
+		metrics.CommandsExecuted.WithLabelValues(response.Command, metrics.ResultSuccess).Inc()
+	} else {
+		metrics.CommandsExecuted.WithLabelValues(commandLabel(response.Command), metrics.ResultFailure).Inc()
+		log.Printf("Command %s for %s failed: %s\n", response.Command, deviceTopic, response.Error)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Could not serialize response for %s: %v\n", deviceTopic, err)
+		return
+	}
+	responseTopic := t.config.Prefix + "/" + deviceTopic + TopicSuffixResponse
+	if err = t.client.Publish(responseTopic, data, false); err != nil {
+		log.Printf("Could not publish response to %s: %v\n", responseTopic, err)
+	}
+}
+
+// isDevice returns true if the topic matches one of the device topic patterns, ignoring case
+func (t *Handler) isDevice(topic string) bool {
+	for _, pattern := range t.config.Topics {
+		if mqtt.MatchTopic(strings.ToLower(pattern), strings.ToLower(topic)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Handler) execute(topic string, payload []byte) Response {
+	command, err := parseCommand(payload)
+	response := Response{ID: command.ID, Command: command.Command, Topic: topic}
+	if err == nil {
+		log.Printf("Executing command %s for %s\n", command.Command, topic)
+		switch command.Command {
+		case CommandStart:
+			_, err = t.sessions.StartSession(topic)
+		case CommandStop:
+			err = t.sessions.StopSession(topic)
+		case CommandExtend:
+			if command.Duration <= 0 {
+				err = fmt.Errorf("Duration must be positive")
+			} else {
+				_, err = t.sessions.ExtendSession(topic, time.Duration(command.Duration)*time.Second)
+			}
+		case CommandStatus:
+		default:
+			err = fmt.Errorf("Unknown command %s", command.Command)
+		}
+	}
+
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Success = true
+	}
+	if entry, active := t.sessions.Sessions()[topic]; active {
+		response.Active = true
+		// The response is readable by every subscriber, so the password is only added on request
+		response.URL = entry.Session.URL
+		if t.config.Password {
+			response.URL, _ = t.sessions.ShareURL(topic)
+		}
+		response.Expires = &entry.Expires
+	}
+	return response
+}
+
+func parseCommand(payload []byte) (Command, error) {
+	var command Command
+	trimmed := strings.TrimSpace(string(payload))
+	if !strings.HasPrefix(trimmed, "{") {
+		command.Command = trimmed
+		return command, nil
+	}
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return command, fmt.Errorf("Invalid command: %w", err)
+	}
+	return command, nil
+}
+
+// commandLabel limits the metric label to the known commands
+func commandLabel(name string) string {
+	switch name {
+	case CommandStart, CommandStop, CommandExtend, CommandStatus:
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,165 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+type MockSessionManager struct {
+	mock.Mock
+}
+
+func (t *MockSessionManager) Sessions() map[string]store.Entry {
+	args := t.Called()
+	return args.Get(0).(map[string]store.Entry)
+}
+
+func (t *MockSessionManager) ShareURL(topic string) (string, bool) {
+	args := t.Called(topic)
+	return args.String(0), args.Bool(1)
+}
+
+func (t *MockSessionManager) StartSession(topic string) (hauk.Session, error) {
+	args := t.Called(topic)
+	return args.Get(0).(hauk.Session), args.Error(1)
+}
+
+func (t *MockSessionManager) StopSession(topic string) error {
+	args := t.Called(topic)
+	return args.Error(0)
+}
+
+func (t *MockSessionManager) ExtendSession(topic string, duration time.Duration) (store.Entry, error) {
+	args := t.Called(topic, duration)
+	return args.Get(0).(store.Entry), args.Error(1)
+}
+
+type mockClient struct {
+	published map[string][]byte
+}
+
+func (t *mockClient) Subscribe(topic string, handler func(topic string, payload []byte)) {
+}
+
+func (t *mockClient) Publish(topic string, payload []byte, retained bool) error {
+	t.published[topic] = payload
+	return nil
+}
+
+func TestHandleMessage_Start(t *testing.T) {
+	// given
+	expires := time.Unix(1618243873, 0).UTC()
+	sessions := new(MockSessionManager)
+	sessions.On("StartSession", "owntracks/user/phone").Return(hauk.Session{SID: "session"}, nil).Once()
+	sessions.On("Sessions").Return(map[string]store.Entry{"owntracks/user/phone": {Session: hauk.Session{URL: "URL"}, Expires: expires}})
+	client := &mockClient{published: make(map[string][]byte)}
+	handler := New(Config{Prefix: "hauk-snitch", Topics: []string{"owntracks/+/+"}}, sessions, client)
+
+	// when
+	handler.handleMessage("hauk-snitch/owntracks/user/phone/cmd", []byte(`{"id":"42","command":"start"}`))
+
+	// then: link is returned without password
+	sessions.AssertExpectations(t)
+	sessions.AssertNotCalled(t, "ShareURL", "owntracks/user/phone")
+	response := getResponse(t, client, "hauk-snitch/owntracks/user/phone/response")
+	assert.Equal(t, Response{ID: "42", Command: "start", Topic: "owntracks/user/phone", Success: true, Active: true, URL: "URL", Expires: &expires}, response)
+}
+
+func TestHandleMessage_StatusWithPassword(t *testing.T) {
+	// given
+	expires := time.Unix(1618243873, 0).UTC()
+	sessions := new(MockSessionManager)
+	sessions.On("Sessions").Return(map[string]store.Entry{"owntracks/user/phone": {Session: hauk.Session{URL: "URL"}, Expires: expires}})
+	sessions.On("ShareURL", "owntracks/user/phone").Return("URL#password", true).Once()
+	client := &mockClient{published: make(map[string][]byte)}
+	handler := New(Config{Prefix: "hauk-snitch", Topics: []string{"owntracks/+/+"}, Password: true}, sessions, client)
+
+	// when
+	handler.handleMessage("hauk-snitch/owntracks/user/phone/cmd", []byte("status"))
+
+	// then: link is returned with password
+	sessions.AssertExpectations(t)
+	response := getResponse(t, client, "hauk-snitch/owntracks/user/phone/response")
+	assert.Equal(t, "URL#password", response.URL)
+}
+
+func TestHandleMessage_PlainStop(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("StopSession", "owntracks/user/phone").Return(nil).Once()
+	sessions.On("Sessions").Return(map[string]store.Entry{})
+	client := &mockClient{published: make(map[string][]byte)}
+	handler := New(Config{Prefix: "hauk-snitch", Topics: []string{"owntracks/+/+"}}, sessions, client)
+
+	// when
+	handler.handleMessage("hauk-snitch/owntracks/user/phone/cmd", []byte("stop\n"))
+
+	// then
+	sessions.AssertExpectations(t)
+	response := getResponse(t, client, "hauk-snitch/owntracks/user/phone/response")
+	assert.True(t, response.Success)
+	assert.False(t, response.Active)
+}
+
+func TestHandleMessage_Extend(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("ExtendSession", "owntracks/user/phone", time.Hour).Return(store.Entry{}, fmt.Errorf("Session for topic owntracks/user/phone does not exist")).Once()
+	sessions.On("Sessions").Return(map[string]store.Entry{})
+	client := &mockClient{published: make(map[string][]byte)}
+	handler := New(Config{Prefix: "hauk-snitch", Topics: []string{"owntracks/+/+"}}, sessions, client)
+
+	// when
+	handler.handleMessage("hauk-snitch/owntracks/user/phone/cmd", []byte(`{"command":"extend","duration":3600}`))
+
+	// then: error is reported
+	sessions.AssertExpectations(t)
+	response := getResponse(t, client, "hauk-snitch/owntracks/user/phone/response")
+	assert.False(t, response.Success)
+	assert.Equal(t, "Session for topic owntracks/user/phone does not exist", response.Error)
+}
+
+func TestHandleMessage_Invalid(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	sessions.On("Sessions").Return(map[string]store.Entry{})
+	client := &mockClient{published: make(map[string][]byte)}
+	handler := New(Config{Prefix: "hauk-snitch", Topics: []string{"owntracks/+/+"}}, sessions, client)
+
+	// when
+	handler.handleMessage("hauk-snitch/owntracks/user/phone/cmd", []byte("explode"))
+	handler.handleMessage("hauk-snitch/owntracks/user/tablet/cmd", []byte(`{"command":"extend"}`))
+	handler.handleMessage("hauk-snitch/owntracks/user/phone/response", []byte(`{"command":"stop"}`))
+
+	// then: errors are reported, responses are ignored
+	assert.Len(t, client.published, 2)
+	assert.Equal(t, "Unknown command explode", getResponse(t, client, "hauk-snitch/owntracks/user/phone/response").Error)
+	assert.Equal(t, "Duration must be positive", getResponse(t, client, "hauk-snitch/owntracks/user/tablet/response").Error)
+}
+
+func getResponse(t *testing.T, client *mockClient, topic string) Response {
+	var response Response
+	assert.NoError(t, json.Unmarshal(client.published[topic], &response))
+	return response
+}
+
+func TestHandleMessage_NotADevice(t *testing.T) {
+	// given
+	sessions := new(MockSessionManager)
+	client := &mockClient{published: make(map[string][]byte)}
+	handler := New(Config{Prefix: "hauk-snitch", Topics: []string{"owntracks/+/+"}}, sessions, client)
+
+	// when
+	handler.handleMessage("hauk-snitch/somebody/else/cmd", []byte("start"))
+
+	// then: nothing is executed or published
+	sessions.AssertExpectations(t)
+	assert.Empty(t, client.published)
+}
@@ -2,12 +2,22 @@ package config
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"github.com/tuffnerdstuff/hauk-snitch/api"
+	"github.com/tuffnerdstuff/hauk-snitch/command"
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
 	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/ingest"
 	"github.com/tuffnerdstuff/hauk-snitch/mapper"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
 	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
 	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
 )
 
 // LoadConfig loads config.toml
@@ -20,12 +30,18 @@ func LoadConfig() {
 	setHaukDefaults()
 	setMapperDefaults()
 	setNotificationDefaults()
+	setStoreDefaults()
+	setAPIDefaults()
+	setMetricsDefaults()
+	setIngestDefaults()
+	setCommandDefaults()
 	readConfigFromFile()
 }
 
 // GetMqttConfig returns a struct containing mqtt config values
 func GetMqttConfig() mqtt.Config {
 	var mqttConfig mqtt.Config
+	mqttConfig.Enabled = viper.GetBool("mqtt.enabled")
 	mqttConfig.Host = viper.GetString("mqtt.host")
 	mqttConfig.Port = viper.GetInt("mqtt.port")
 	mqttConfig.Topic = viper.GetString("mqtt.topic")
@@ -33,9 +49,84 @@ func GetMqttConfig() mqtt.Config {
 	mqttConfig.Password = viper.GetString("mqtt.password")
 	mqttConfig.IsAnonymous = viper.GetBool("mqtt.anonymous")
 	mqttConfig.IsTLS = viper.GetBool("mqtt.tls")
+	mqttConfig.IsInsecure = viper.GetBool("mqtt.insecure")
+	mqttConfig.CAFile = viper.GetString("mqtt.ca_file")
+	mqttConfig.CertFile = viper.GetString("mqtt.cert_file")
+	mqttConfig.KeyFile = viper.GetString("mqtt.key_file")
+	mqttConfig.ServerName = viper.GetString("mqtt.server_name")
+	mqttConfig.ConnectRetries = viper.GetInt("mqtt.connect_retries")
+	mqttConfig.ConnectRetryInitial = time.Duration(viper.GetInt("mqtt.connect_retry_initial")) * time.Second
+	mqttConfig.ConnectRetryMax = time.Duration(viper.GetInt("mqtt.connect_retry_max")) * time.Second
+	mqttConfig.ReconnectMax = time.Duration(viper.GetInt("mqtt.reconnect_max")) * time.Second
+	if mqttConfig.ConnectRetryInitial <= 0 || mqttConfig.ConnectRetryMax < mqttConfig.ConnectRetryInitial {
+		panic(fmt.Errorf("Config error: mqtt.connect_retry_initial must be positive and not exceed mqtt.connect_retry_max"))
+	}
+	mqttConfig.EncryptionKey = viper.GetString("mqtt.encryption_key")
+	if err := viper.UnmarshalKey("mqtt.encryption_keys", &mqttConfig.EncryptionKeys); err != nil {
+		panic(fmt.Errorf("Config error in mqtt.encryption_keys: %w", err))
+	}
+	mqttConfig.Mappings = getMappingsConfig()
 	return mqttConfig
 }
 
+// mappingConfig is a [[mappings]] block mapping payloads of other publishers to locations
+type mappingConfig struct {
+	Topic  string               `mapstructure:"topic"`
+	Extend bool                 `mapstructure:"extend"`
+	Fields []mappingFieldConfig `mapstructure:"fields"`
+}
+
+// mappingFieldConfig is a [[mappings.fields]] block
+type mappingFieldConfig struct {
+	Key    string   `mapstructure:"key"`
+	Target string   `mapstructure:"target"`
+	Unit   string   `mapstructure:"unit"`
+	Scale  *float64 `mapstructure:"scale"`
+	Offset float64  `mapstructure:"offset"`
+	Type   string   `mapstructure:"type"`
+}
+
+func getMappingsConfig() []mqtt.Mapping {
+	var mappingConfigs []mappingConfig
+	if err := viper.UnmarshalKey("mappings", &mappingConfigs); err != nil {
+		panic(fmt.Errorf("Config error in mappings: %w", err))
+	}
+
+	var mappings []mqtt.Mapping
+	for _, mappingConfig := range mappingConfigs {
+		if mappingConfig.Topic == "" {
+			panic(fmt.Errorf("Config error: mappings must have a topic"))
+		}
+		mapping := mqtt.Mapping{Topic: mappingConfig.Topic, Extend: mappingConfig.Extend, Fields: source.KeyMapping{}}
+		targets := make(map[string]string)
+		for _, fieldConfig := range mappingConfig.Fields {
+			field, err := source.NewFieldMapping(source.FieldConfig{
+				Key:    fieldConfig.Key,
+				Target: fieldConfig.Target,
+				Unit:   fieldConfig.Unit,
+				Scale:  fieldConfig.Scale,
+				Offset: fieldConfig.Offset,
+				Type:   fieldConfig.Type,
+			})
+			if err != nil {
+				panic(fmt.Errorf("Config error in mappings of %s: %w", mappingConfig.Topic, err))
+			}
+			if key, duplicate := targets[field.Field]; duplicate {
+				panic(fmt.Errorf("Config error in mappings of %s: keys %s and %s both map to %s", mappingConfig.Topic, key, fieldConfig.Key, field.Field))
+			}
+			targets[field.Field] = fieldConfig.Key
+			mapping.Fields[fieldConfig.Key] = field
+		}
+		if !mapping.Extend {
+			if err := mapping.Fields.Validate(); err != nil {
+				panic(fmt.Errorf("Config error in mappings of %s: %w", mappingConfig.Topic, err))
+			}
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
 // GetHaukConfig returns a struct containing hauk config values
 func GetHaukConfig() hauk.Config {
 	var haukConfig hauk.Config
@@ -47,6 +138,7 @@ func GetHaukConfig() hauk.Config {
 	haukConfig.IsTLS = viper.GetBool("hauk.tls")
 	haukConfig.Duration = viper.GetInt("hauk.duration")
 	haukConfig.Interval = viper.GetInt("hauk.interval")
+	haukConfig.Timeout = time.Duration(viper.GetInt("hauk.timeout")) * time.Second
 	return haukConfig
 }
 
@@ -55,27 +147,330 @@ func GetMapperConfig() mapper.Config {
 	mapperConfig.SessionStartAuto = viper.GetBool(("mapper.start_session_auto"))
 	mapperConfig.SessionStartManual = viper.GetBool(("mapper.start_session_manual"))
 	mapperConfig.SessionStopAuto = viper.GetBool(("mapper.stop_session_auto"))
+	mapperConfig.SessionDuration = time.Duration(viper.GetInt("hauk.duration")) * time.Second
+	mapperConfig.SilentAfter = time.Duration(viper.GetInt("mapper.silent_after")) * time.Second
+	mapperConfig.E2EPassword = viper.GetString("hauk.e2e_password")
+	if err := viper.UnmarshalKey("hauk.e2e_passwords", &mapperConfig.E2EPasswords); err != nil {
+		panic(fmt.Errorf("Config error in hauk.e2e_passwords: %w", err))
+	}
+	mapperConfig.Queue.Enabled = viper.GetBool("queue.enabled")
+	mapperConfig.Queue.Mode = viper.GetString("queue.mode")
+	mapperConfig.Queue.Size = viper.GetInt("queue.size")
+	mapperConfig.Queue.RetryInitial = time.Duration(viper.GetInt("queue.retry_initial")) * time.Second
+	mapperConfig.Queue.RetryMax = time.Duration(viper.GetInt("queue.retry_max")) * time.Second
+	mapperConfig.Queue.Path = viper.GetString("queue.path")
+	if mapperConfig.Queue.Mode != mapper.QueueModeAll && mapperConfig.Queue.Mode != mapper.QueueModeLatest {
+		panic(fmt.Errorf("Config error: queue.mode must be %s or %s", mapper.QueueModeAll, mapper.QueueModeLatest))
+	}
+	if mapperConfig.Queue.RetryInitial <= 0 || mapperConfig.Queue.RetryMax < mapperConfig.Queue.RetryInitial {
+		panic(fmt.Errorf("Config error: queue.retry_initial must be positive and not exceed queue.retry_max"))
+	}
+	mapperConfig.Devices = getDevicesConfig()
+	mapperConfig.Stale.DropOutOfOrder = viper.GetBool("stale.drop_out_of_order")
+	mapperConfig.Stale.DropDuplicates = viper.GetBool("stale.drop_duplicates")
+	mapperConfig.Stale.MaxAge = time.Duration(viper.GetInt("stale.max_age")) * time.Second
+	mapperConfig.Stale.Backlog = viper.GetString("stale.backlog")
+	if mapperConfig.Stale.Backlog != mapper.BacklogDrop && mapperConfig.Stale.Backlog != mapper.BacklogTrail {
+		panic(fmt.Errorf("Config error: stale.backlog must be %s or %s", mapper.BacklogDrop, mapper.BacklogTrail))
+	}
+	mapperConfig.Filter.MaxAccuracy = viper.GetFloat64("filter.max_accuracy")
+	mapperConfig.Filter.MinDistance = viper.GetFloat64("filter.min_distance")
+	mapperConfig.Filter.MaxSpeed = viper.GetFloat64("filter.max_speed") / 3.6 // km/h to m/s
+	mapperConfig.Filter.Mode = viper.GetString("filter.mode")
+	checkFilterMode("filter.mode", mapperConfig.Filter.Mode)
+	mapperConfig.PrivacyZones = getPrivacyZonesConfig()
+	mapperConfig.RateLimit.Enabled = viper.GetBool("rate_limit.enabled")
+	mapperConfig.RateLimit.Interval = time.Duration(viper.GetInt("hauk.interval")) * time.Second
+	mapperConfig.Publish.Session = viper.GetBool("mqtt.publish_session")
+	mapperConfig.Publish.Cmd = viper.GetBool("mqtt.publish_cmd")
+	mapperConfig.Publish.Password = viper.GetBool("mqtt.publish_password")
+	mapperConfig.Geofence.Enabled = viper.GetBool("geofence.enabled")
+	mapperConfig.Geofence.Transitions = viper.GetBool("geofence.transitions")
+	mapperConfig.Geofence.TransitionRegions = viper.GetStringSlice("geofence.transition_regions")
+	if err := viper.UnmarshalKey("geofence.regions", &mapperConfig.Geofence.Regions); err != nil {
+		panic(fmt.Errorf("Config error in geofence.regions: %w", err))
+	}
 	return mapperConfig
 }
 
-// GetNotificationConfig returns a struct containing email notification configuration
+// deviceConfig is the [devices."<topic>"] block of a device, unset values are nil
+type deviceConfig struct {
+	Name               string              `mapstructure:"name"`
+	Duration           *int                `mapstructure:"duration"`
+	Interval           *int                `mapstructure:"interval"`
+	SilentAfter        *int                `mapstructure:"silent_after"`
+	StartSessionAuto   *bool               `mapstructure:"start_session_auto"`
+	StopSessionAuto    *bool               `mapstructure:"stop_session_auto"`
+	StartSessionManual *bool               `mapstructure:"start_session_manual"`
+	E2EPassword        *string             `mapstructure:"e2e_password"`
+	Notify             []string            `mapstructure:"notify"`
+	EmailTo            []string            `mapstructure:"email_to"`
+	Recipients         map[string][]string `mapstructure:"recipients"`
+	MaxAccuracy        *float64            `mapstructure:"max_accuracy"`
+	MinDistance        *float64            `mapstructure:"min_distance"`
+	MaxSpeed           *float64            `mapstructure:"max_speed"`
+	FilterMode         *string             `mapstructure:"filter_mode"`
+}
+
+func getDevicesConfig() []mapper.DeviceConfig {
+	var deviceConfigs map[string]deviceConfig
+	if err := viper.UnmarshalKey("devices", &deviceConfigs); err != nil {
+		panic(fmt.Errorf("Config error in devices: %w", err))
+	}
+
+	var devices []mapper.DeviceConfig
+	for topic, deviceConfig := range deviceConfigs {
+		device := mapper.DeviceConfig{
+			Topic:              topic,
+			Name:               deviceConfig.Name,
+			SessionStartAuto:   deviceConfig.StartSessionAuto,
+			SessionStopAuto:    deviceConfig.StopSessionAuto,
+			SessionStartManual: deviceConfig.StartSessionManual,
+			E2EPassword:        deviceConfig.E2EPassword,
+			NotifyChannels:     deviceConfig.Notify,
+			NotifyEmailTo:      deviceConfig.EmailTo,
+			NotifyRecipients:   deviceConfig.Recipients,
+			MaxAccuracy:        deviceConfig.MaxAccuracy,
+			MinDistance:        deviceConfig.MinDistance,
+			FilterMode:         deviceConfig.FilterMode,
+		}
+		// An empty list disables notifications, but is decoded as nil, likewise the recipients of a channel
+		if device.NotifyChannels == nil && viper.IsSet(fmt.Sprintf("devices.%s.notify", topic)) {
+			device.NotifyChannels = []string{}
+		}
+		for channel, recipients := range device.NotifyRecipients {
+			if recipients == nil {
+				device.NotifyRecipients[channel] = []string{}
+			}
+		}
+		if deviceConfig.Duration != nil {
+			duration := time.Duration(*deviceConfig.Duration) * time.Second
+			device.SessionDuration = &duration
+		}
+		if deviceConfig.Interval != nil {
+			interval := time.Duration(*deviceConfig.Interval) * time.Second
+			device.SessionInterval = &interval
+		}
+		if deviceConfig.SilentAfter != nil {
+			silentAfter := time.Duration(*deviceConfig.SilentAfter) * time.Second
+			device.SilentAfter = &silentAfter
+		}
+		if deviceConfig.MaxSpeed != nil {
+			maxSpeed := *deviceConfig.MaxSpeed / 3.6 // km/h to m/s
+			device.MaxSpeed = &maxSpeed
+		}
+		if deviceConfig.FilterMode != nil {
+			checkFilterMode(fmt.Sprintf("devices.%s.filter_mode", topic), *deviceConfig.FilterMode)
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// privacyZoneConfig is a [[privacy.zones]] block, either radius or polygon must be set
+type privacyZoneConfig struct {
+	Name         string      `mapstructure:"name"`
+	Topic        string      `mapstructure:"topic"`
+	Latitude     float64     `mapstructure:"latitude"`
+	Longitude    float64     `mapstructure:"longitude"`
+	Radius       float64     `mapstructure:"radius"`
+	Polygon      [][]float64 `mapstructure:"polygon"`
+	Mode         string      `mapstructure:"mode"`
+	GridSize     float64     `mapstructure:"grid_size"`
+	PauseSession bool        `mapstructure:"pause_session"`
+}
+
+func getPrivacyZonesConfig() []mapper.PrivacyZone {
+	var zoneConfigs []privacyZoneConfig
+	if err := viper.UnmarshalKey("privacy.zones", &zoneConfigs); err != nil {
+		panic(fmt.Errorf("Config error in privacy.zones: %w", err))
+	}
+
+	var zones []mapper.PrivacyZone
+	for _, zoneConfig := range zoneConfigs {
+		zone := mapper.PrivacyZone{
+			Name:         zoneConfig.Name,
+			Topic:        zoneConfig.Topic,
+			Mode:         zoneConfig.Mode,
+			GridSize:     zoneConfig.GridSize,
+			PauseSession: zoneConfig.PauseSession,
+		}
+		if zone.Mode == "" {
+			zone.Mode = mapper.PrivacyModeSuppress
+		}
+		switch zone.Mode {
+		case mapper.PrivacyModeSuppress, mapper.PrivacyModeEdge, mapper.PrivacyModeCentroid:
+		case mapper.PrivacyModeGrid:
+			if zone.GridSize <= 0 {
+				panic(fmt.Errorf("Config error: privacy zone %s requires a positive grid_size", zone.Name))
+			}
+		default:
+			panic(fmt.Errorf("Config error: mode of privacy zone %s must be %s, %s, %s or %s", zone.Name,
+				mapper.PrivacyModeSuppress, mapper.PrivacyModeEdge, mapper.PrivacyModeCentroid, mapper.PrivacyModeGrid))
+		}
+
+		if len(zoneConfig.Polygon) > 0 {
+			if len(zoneConfig.Polygon) < 3 {
+				panic(fmt.Errorf("Config error: polygon of privacy zone %s needs at least 3 points", zone.Name))
+			}
+			var polygon geo.Polygon
+			for _, vertex := range zoneConfig.Polygon {
+				if len(vertex) != 2 {
+					panic(fmt.Errorf("Config error: polygon of privacy zone %s must consist of [latitude, longitude] points", zone.Name))
+				}
+				polygon.Vertices = append(polygon.Vertices, geo.Point{Latitude: vertex[0], Longitude: vertex[1]})
+			}
+			zone.Area = polygon
+		} else if zoneConfig.Radius > 0 {
+			// the center of a circle is usually the place to hide, which both modes would give away
+			if zone.Mode == mapper.PrivacyModeEdge || zone.Mode == mapper.PrivacyModeCentroid {
+				panic(fmt.Errorf("Config error: mode of circular privacy zone %s must be %s or %s", zone.Name,
+					mapper.PrivacyModeSuppress, mapper.PrivacyModeGrid))
+			}
+			zone.Area = geo.Circle{Center: geo.Point{Latitude: zoneConfig.Latitude, Longitude: zoneConfig.Longitude}, Radius: zoneConfig.Radius}
+		} else {
+			panic(fmt.Errorf("Config error: privacy zone %s requires a radius or a polygon", zone.Name))
+		}
+		zones = append(zones, zone)
+	}
+	return zones
+}
+
+func checkFilterMode(key string, mode string) {
+	if mode != mapper.FilterModeDrop && mode != mapper.FilterModeSmooth {
+		panic(fmt.Errorf("Config error: %s must be %s or %s", key, mapper.FilterModeDrop, mapper.FilterModeSmooth))
+	}
+}
+
+// GetStoreConfig returns a struct containing session store config values
+func GetStoreConfig() store.Config {
+	var storeConfig store.Config
+	storeConfig.Type = viper.GetString("store.type")
+	storeConfig.Path = viper.GetString("store.path")
+	return storeConfig
+}
+
+// GetNotificationConfig returns a struct containing the config of all [notification.<name>] blocks
 func GetNotificationConfig() notification.Config {
 	var notificationConfig notification.Config
-	notificationConfig.Smtp.Enabled = viper.GetBool("notification.smtp.enabled")
-	notificationConfig.Smtp.Host = viper.GetString("notification.smtp.smtp_host")
-	notificationConfig.Smtp.Port = viper.GetInt("notification.smtp.smtp_port")
-	notificationConfig.Smtp.Login = viper.GetString("notification.smtp_login")
-	notificationConfig.Smtp.Password = viper.GetString("notification.smtp_password")
-	notificationConfig.Smtp.From = viper.GetString("notification.smtp.from")
-	notificationConfig.Smtp.To = viper.GetString("notification.smtp.to")
-
-	notificationConfig.Gotify.Enabled = viper.GetBool("notification.gotify.enabled")
-	notificationConfig.Gotify.URL = viper.GetString("notification.gotify.url")
-	notificationConfig.Gotify.AppToken = viper.GetString("notification.gotify.app_token")
-	notificationConfig.Gotify.Priority = viper.GetInt("notification.gotify.priority")
+	var names []string
+	for name := range viper.GetStringMap("notification") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key := "notification." + name
+		channelConfig := notification.ChannelConfig{
+			Name:    name,
+			Type:    viper.GetString(key + ".type"),
+			Enabled: viper.GetBool(key + ".enabled"),
+			Decode: func(target interface{}) error {
+				if err := viper.UnmarshalKey(key, target); err != nil {
+					return fmt.Errorf("Config error in %s: %w", key, err)
+				}
+				return nil
+			},
+		}
+		// Without events the channel is subscribed to all, an empty list to none
+		if viper.IsSet(key + ".events") {
+			channelConfig.Events = viper.GetStringSlice(key + ".events")
+			if channelConfig.Events == nil {
+				channelConfig.Events = []string{}
+			}
+		}
+		if err := viper.UnmarshalKey(key+".templates", &channelConfig.Templates); err != nil {
+			panic(fmt.Errorf("Config error in %s.templates: %w", key, err))
+		}
+		// Unset delivery values are replaced by the defaults of the notification package
+		channelConfig.Delivery = notification.DeliveryConfig{
+			Timeout:      time.Duration(viper.GetInt(key+".timeout")) * time.Second,
+			Retries:      3, // 0 disables retries, so the default is applied here
+			RetryInitial: time.Duration(viper.GetInt(key+".retry_initial")) * time.Second,
+			RetryMax:     time.Duration(viper.GetInt(key+".retry_max")) * time.Second,
+			QueueSize:    viper.GetInt(key + ".queue_size"),
+		}
+		if viper.IsSet(key + ".retries") {
+			channelConfig.Delivery.Retries = viper.GetInt(key + ".retries")
+		}
+		// The type defaults to the name, so [notification.smtp] is an smtp channel
+		if channelConfig.Type == "" {
+			channelConfig.Type = name
+		}
+		notificationConfig.Channels = append(notificationConfig.Channels, channelConfig)
+	}
 	return notificationConfig
 }
 
+// GetAPIConfig returns a struct containing admin API config values
+func GetAPIConfig() api.Config {
+	var apiConfig api.Config
+	apiConfig.Enabled = viper.GetBool("api.enabled")
+	apiConfig.Host = viper.GetString("api.host")
+	apiConfig.Port = viper.GetInt("api.port")
+	apiConfig.Token = viper.GetString("api.token")
+	return apiConfig
+}
+
+// GetMetricsConfig returns a struct containing metrics endpoint config values
+func GetMetricsConfig() metrics.Config {
+	var metricsConfig metrics.Config
+	metricsConfig.Enabled = viper.GetBool("metrics.enabled")
+	metricsConfig.Host = viper.GetString("metrics.host")
+	metricsConfig.Port = viper.GetInt("metrics.port")
+	return metricsConfig
+}
+
+// GetIngestConfig returns a struct containing OwnTracks HTTP endpoint config values
+func GetIngestConfig() ingest.Config {
+	var ingestConfig ingest.Config
+	ingestConfig.Enabled = viper.GetBool("http.enabled")
+	ingestConfig.Host = viper.GetString("http.host")
+	ingestConfig.Port = viper.GetInt("http.port")
+	ingestConfig.Path = viper.GetString("http.path")
+	ingestConfig.TopicPrefix = viper.GetString("http.topic_prefix")
+	if err := viper.UnmarshalKey("http.users", &ingestConfig.Users); err != nil {
+		panic(fmt.Errorf("Config error in http.users: %w", err))
+	}
+	for _, user := range ingestConfig.Users {
+		if user.User == "" || user.Password == "" {
+			panic(fmt.Errorf("Config error: http.users must have a user and password"))
+		}
+		// The user is a topic level of the locations
+		if strings.ContainsAny(user.User, "/+#") {
+			panic(fmt.Errorf("Config error: http.users user %s must not contain /, + or #", user.User))
+		}
+	}
+	return ingestConfig
+}
+
+// GetCommandConfig returns a struct containing MQTT command topic config values
+func GetCommandConfig() command.Config {
+	var commandConfig command.Config
+	commandConfig.Enabled = viper.GetBool("commands.enabled")
+	commandConfig.Prefix = strings.TrimSuffix(viper.GetString("commands.prefix"), "/")
+	commandConfig.Password = viper.GetBool("mqtt.publish_password")
+	if commandConfig.Enabled && commandConfig.Prefix == "" {
+		panic(fmt.Errorf("Config error: commands.prefix must be set if commands are enabled"))
+	}
+	if strings.ContainsAny(commandConfig.Prefix, "+#") {
+		panic(fmt.Errorf("Config error: commands.prefix must not contain wildcards"))
+	}
+	// Commands are accepted for the topics locations are received on and the configured devices
+	if viper.GetBool("mqtt.enabled") {
+		commandConfig.Topics = append(commandConfig.Topics, viper.GetString("mqtt.topic"))
+		for _, mapping := range getMappingsConfig() {
+			commandConfig.Topics = append(commandConfig.Topics, mapping.Topic)
+		}
+	}
+	if viper.GetBool("http.enabled") {
+		commandConfig.Topics = append(commandConfig.Topics, strings.TrimSuffix(viper.GetString("http.topic_prefix"), "/")+"/+/+")
+	}
+	for _, device := range getDevicesConfig() {
+		commandConfig.Topics = append(commandConfig.Topics, device.Topic)
+	}
+	return commandConfig
+}
+
 func readConfigFromFile() {
 	viper.SetConfigName("config")
 	viper.SetConfigType(viper.GetString("config_type"))
@@ -89,6 +484,10 @@ func readConfigFromFile() {
 }
 
 func setMqttDefaults() {
+	viper.SetDefault("mqtt.enabled", true)
+	viper.SetDefault("mqtt.publish_session", false)
+	viper.SetDefault("mqtt.publish_cmd", false)
+	viper.SetDefault("mqtt.publish_password", false)
 	viper.SetDefault("mqtt.host", "localhost")
 	viper.SetDefault("mqtt.port", 1883)
 	viper.SetDefault("mqtt.topic", "owntracks/+/+")
@@ -96,23 +495,57 @@ func setMqttDefaults() {
 	viper.SetDefault("mqtt.password", "")
 	viper.SetDefault("mqtt.anonymous", true)
 	viper.SetDefault("mqtt.tls", false)
+	viper.SetDefault("mqtt.insecure", false)
+	viper.SetDefault("mqtt.ca_file", "")
+	viper.SetDefault("mqtt.cert_file", "")
+	viper.SetDefault("mqtt.key_file", "")
+	viper.SetDefault("mqtt.server_name", "")
+	viper.SetDefault("mqtt.connect_retries", 0)       // Retry forever
+	viper.SetDefault("mqtt.connect_retry_initial", 1) // 1 second
+	viper.SetDefault("mqtt.connect_retry_max", 60)    // 1 minute
+	viper.SetDefault("mqtt.reconnect_max", 60)        // 1 minute
+	viper.SetDefault("mqtt.encryption_key", "")
 }
 
 func setHaukDefaults() {
 	viper.SetDefault("hauk.host", "localhost")
 	viper.SetDefault("hauk.port", 80)
+	viper.SetDefault("hauk.timeout", 10) // 10 seconds
 	viper.SetDefault("hauk.user", "")
 	viper.SetDefault("hauk.password", "")
 	viper.SetDefault("hauk.anonymous", true)
 	viper.SetDefault("hauk.tls", false)
 	viper.SetDefault("hauk.duration", 3600) // 1 hour
 	viper.SetDefault("hauk.interval", 1)    // Every second
+	viper.SetDefault("hauk.e2e_password", "")
 }
 
 func setMapperDefaults() {
 	viper.SetDefault("mapper.stop_session_auto", true)
 	viper.SetDefault("mapper.start_session_auto", true)
 	viper.SetDefault("mapper.start_session_manual", true)
+	viper.SetDefault("mapper.silent_after", 0) // disabled
+
+	viper.SetDefault("queue.enabled", false)
+	viper.SetDefault("queue.mode", mapper.QueueModeAll)
+	viper.SetDefault("queue.size", 100)
+	viper.SetDefault("queue.retry_initial", 1) // 1 second
+	viper.SetDefault("queue.retry_max", 300)   // 5 minutes
+	viper.SetDefault("queue.path", "")
+
+	viper.SetDefault("geofence.enabled", false)
+	viper.SetDefault("geofence.transitions", true)
+	viper.SetDefault("geofence.transition_regions", []string{})
+
+	viper.SetDefault("stale.drop_out_of_order", true)
+	viper.SetDefault("stale.drop_duplicates", true)
+	viper.SetDefault("stale.max_age", 0) // disabled
+	viper.SetDefault("stale.backlog", mapper.BacklogDrop)
+	viper.SetDefault("filter.max_accuracy", 0) // disabled
+	viper.SetDefault("filter.min_distance", 0) // disabled
+	viper.SetDefault("filter.max_speed", 0)    // disabled
+	viper.SetDefault("filter.mode", mapper.FilterModeDrop)
+	viper.SetDefault("rate_limit.enabled", false)
 
 }
 
@@ -130,3 +563,34 @@ func setNotificationDefaults() {
 	viper.SetDefault("notification.gotify.app_token", "")
 	viper.SetDefault("notification.gotify.priority", 5)
 }
+
+func setStoreDefaults() {
+	viper.SetDefault("store.type", store.TypeMemory)
+	viper.SetDefault("store.path", "/var/lib/hauk-snitch/sessions.json")
+}
+
+func setAPIDefaults() {
+	viper.SetDefault("api.enabled", false)
+	viper.SetDefault("api.host", "")
+	viper.SetDefault("api.port", 8080)
+	viper.SetDefault("api.token", "")
+}
+
+func setMetricsDefaults() {
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.host", "")
+	viper.SetDefault("metrics.port", 9100)
+}
+
+func setIngestDefaults() {
+	viper.SetDefault("http.enabled", false)
+	viper.SetDefault("http.host", "")
+	viper.SetDefault("http.port", 8083)
+	viper.SetDefault("http.path", "/pub")
+	viper.SetDefault("http.topic_prefix", "owntracks")
+}
+
+func setCommandDefaults() {
+	viper.SetDefault("commands.enabled", false)
+	viper.SetDefault("commands.prefix", "hauk-snitch")
+}
@@ -0,0 +1,85 @@
+package geo
+
+import "math"
+
+// EarthRadius is the mean radius of the earth in meters
+const EarthRadius float64 = 6371000
+
+// metersPerDegree is the length of one degree of latitude in meters
+const metersPerDegree float64 = EarthRadius * math.Pi / 180
+
+// Point is a position on earth in degrees
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Distance returns the great-circle distance between two points in meters (haversine formula)
+func Distance(a Point, b Point) float64 {
+	lat1 := toRadians(a.Latitude)
+	lat2 := toRadians(b.Latitude)
+	deltaLat := toRadians(b.Latitude - a.Latitude)
+	deltaLon := toRadians(b.Longitude - a.Longitude)
+
+	h := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return 2 * EarthRadius * math.Asin(math.Sqrt(h))
+}
+
+// Interpolate returns the point at the fraction (0 to 1) of the way from a to b.
+// It interpolates linearly, which is precise enough for short distances.
+func Interpolate(a Point, b Point, fraction float64) Point {
+	return Point{
+		Latitude:  a.Latitude + (b.Latitude-a.Latitude)*fraction,
+		Longitude: a.Longitude + (b.Longitude-a.Longitude)*fraction,
+	}
+}
+
+// Area is a region on earth
+type Area interface {
+	// Contains returns true if the point lies within the area
+	Contains(point Point) bool
+	// Centroid returns the center of the area
+	Centroid() Point
+	// Edge returns the point on the border of the area closest to the point
+	Edge(point Point) Point
+}
+
+// Circle is a circular area around a center point
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// Contains returns true if the point lies within the circle
+func (t Circle) Contains(point Point) bool {
+	return Distance(t.Center, point) <= t.Radius
+}
+
+// Centroid returns the center of the circle
+func (t Circle) Centroid() Point {
+	return t.Center
+}
+
+// Edge returns the point on the circle closest to the point
+func (t Circle) Edge(point Point) Point {
+	distance := Distance(t.Center, point)
+	if distance == 0 {
+		// Every point on the circle is equally close, pick the northernmost
+		return Point{Latitude: t.Center.Latitude + t.Radius/metersPerDegree, Longitude: t.Center.Longitude}
+	}
+	return Interpolate(t.Center, point, t.Radius/distance)
+}
+
+// Snap returns the center of the grid cell of the given size in meters containing the point
+func Snap(point Point, size float64) Point {
+	latitudeStep := size / metersPerDegree
+	latitude := (math.Floor(point.Latitude/latitudeStep) + 0.5) * latitudeStep
+	longitudeStep := latitudeStep / math.Max(math.Cos(toRadians(latitude)), 0.01)
+	longitude := (math.Floor(point.Longitude/longitudeStep) + 0.5) * longitudeStep
+	return Point{Latitude: latitude, Longitude: longitude}
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
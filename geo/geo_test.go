@@ -0,0 +1,48 @@
+package geo
+
+import (
+	"math"
+
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	// One degree of latitude is about 111.2 km
+	a := Point{Latitude: 47, Longitude: 13}
+	b := Point{Latitude: 48, Longitude: 13}
+	assert.InDelta(t, 111195, Distance(a, b), 1)
+	assert.InDelta(t, 111195, Distance(b, a), 1)
+	assert.Equal(t, float64(0), Distance(a, a))
+}
+
+func TestCircle_Contains(t *testing.T) {
+	circle := Circle{Center: Point{Latitude: 47.5968792, Longitude: 12.9540961}, Radius: 100}
+	assert.True(t, circle.Contains(Point{Latitude: 47.5969, Longitude: 12.9541}))
+	assert.False(t, circle.Contains(Point{Latitude: 47.6, Longitude: 12.96}))
+}
+
+func TestInterpolate(t *testing.T) {
+	a := Point{Latitude: 47, Longitude: 13}
+	b := Point{Latitude: 48, Longitude: 14}
+	assert.Equal(t, a, Interpolate(a, b, 0))
+	assert.Equal(t, b, Interpolate(a, b, 1))
+	assert.Equal(t, Point{Latitude: 47.25, Longitude: 13.25}, Interpolate(a, b, 0.25))
+}
+
+func TestCircle_Edge(t *testing.T) {
+	circle := Circle{Center: Point{Latitude: 47, Longitude: 13}, Radius: 100}
+	edge := circle.Edge(Point{Latitude: 47.0001, Longitude: 13})
+	assert.InDelta(t, 100, Distance(circle.Center, edge), 0.1)
+	assert.Equal(t, float64(13), edge.Longitude)
+	assert.InDelta(t, 100, Distance(circle.Center, circle.Edge(circle.Center)), 0.1)
+}
+
+func TestSnap(t *testing.T) {
+	// Points in the same cell snap to the same point, which is at most half a cell diagonal away
+	a := Point{Latitude: 47.59601, Longitude: 12.95401}
+	b := Point{Latitude: 47.59602, Longitude: 12.95402}
+	assert.Equal(t, Snap(a, 1000), Snap(b, 1000))
+	assert.Less(t, Distance(a, Snap(a, 1000)), 1000*math.Sqrt2/2)
+}
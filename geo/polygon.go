@@ -0,0 +1,82 @@
+package geo
+
+import "math"
+
+// Polygon is an area enclosed by its vertices, the last vertex is connected to the first one.
+// Its edges are treated as straight lines in degrees, which is precise enough for small areas.
+type Polygon struct {
+	Vertices []Point
+}
+
+// Contains returns true if the point lies within the polygon (ray casting)
+func (t Polygon) Contains(point Point) bool {
+	inside := false
+	for i, j := 0, len(t.Vertices)-1; i < len(t.Vertices); j, i = i, i+1 {
+		a, b := t.Vertices[i], t.Vertices[j]
+		if (a.Latitude > point.Latitude) != (b.Latitude > point.Latitude) &&
+			point.Longitude < (b.Longitude-a.Longitude)*(point.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Centroid returns the center of mass of the polygon
+func (t Polygon) Centroid() Point {
+	if len(t.Vertices) == 0 {
+		return Point{}
+	}
+	// Coordinates relative to the first vertex avoid losing precision
+	origin := t.Vertices[0]
+	var area, latitude, longitude float64
+	for i, j := 0, len(t.Vertices)-1; i < len(t.Vertices); j, i = i, i+1 {
+		aLat, aLon := t.Vertices[j].Latitude-origin.Latitude, t.Vertices[j].Longitude-origin.Longitude
+		bLat, bLon := t.Vertices[i].Latitude-origin.Latitude, t.Vertices[i].Longitude-origin.Longitude
+		cross := aLon*bLat - bLon*aLat
+		area += cross
+		longitude += (aLon + bLon) * cross
+		latitude += (aLat + bLat) * cross
+	}
+	if area == 0 {
+		return t.vertexAverage()
+	}
+	return Point{Latitude: origin.Latitude + latitude/(3*area), Longitude: origin.Longitude + longitude/(3*area)}
+}
+
+// Edge returns the point on the border of the polygon closest to the point
+func (t Polygon) Edge(point Point) Point {
+	closest := point
+	minDistance := math.Inf(1)
+	for i, j := 0, len(t.Vertices)-1; i < len(t.Vertices); j, i = i, i+1 {
+		candidate := closestOnSegment(t.Vertices[j], t.Vertices[i], point)
+		if distance := Distance(candidate, point); distance < minDistance {
+			closest = candidate
+			minDistance = distance
+		}
+	}
+	return closest
+}
+
+func (t Polygon) vertexAverage() Point {
+	var sum Point
+	for _, vertex := range t.Vertices {
+		sum.Latitude += vertex.Latitude
+		sum.Longitude += vertex.Longitude
+	}
+	count := float64(len(t.Vertices))
+	return Point{Latitude: sum.Latitude / count, Longitude: sum.Longitude / count}
+}
+
+// closestOnSegment projects the point onto the segment from a to b.
+// Longitudes are scaled, so the projection is not distorted away from the equator.
+func closestOnSegment(a Point, b Point, point Point) Point {
+	scale := math.Cos(toRadians(point.Latitude))
+	dx := (b.Longitude - a.Longitude) * scale
+	dy := b.Latitude - a.Latitude
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return a
+	}
+	fraction := ((point.Longitude-a.Longitude)*scale*dx + (point.Latitude-a.Latitude)*dy) / lengthSquared
+	return Interpolate(a, b, math.Max(0, math.Min(1, fraction)))
+}
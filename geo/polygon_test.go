@@ -0,0 +1,36 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createSquare() Polygon {
+	return Polygon{Vertices: []Point{
+		{Latitude: 47, Longitude: 13},
+		{Latitude: 47, Longitude: 13.01},
+		{Latitude: 47.01, Longitude: 13.01},
+		{Latitude: 47.01, Longitude: 13},
+	}}
+}
+
+func TestPolygon_Contains(t *testing.T) {
+	square := createSquare()
+	assert.True(t, square.Contains(Point{Latitude: 47.005, Longitude: 13.005}))
+	assert.False(t, square.Contains(Point{Latitude: 47.02, Longitude: 13.005}))
+	assert.False(t, square.Contains(Point{Latitude: 47.005, Longitude: 12.99}))
+}
+
+func TestPolygon_Centroid(t *testing.T) {
+	centroid := createSquare().Centroid()
+	assert.InDelta(t, 47.005, centroid.Latitude, 1e-9)
+	assert.InDelta(t, 13.005, centroid.Longitude, 1e-9)
+}
+
+func TestPolygon_Edge(t *testing.T) {
+	// closest edge of a point near the northern border is the northern border
+	edge := createSquare().Edge(Point{Latitude: 47.009, Longitude: 13.005})
+	assert.InDelta(t, 47.01, edge.Latitude, 1e-9)
+	assert.InDelta(t, 13.005, edge.Longitude, 1e-9)
+}
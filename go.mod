@@ -6,10 +6,12 @@ require (
 	github.com/eclipse/paho.mqtt.golang v1.3.3
 	github.com/gotify/go-api-client/v2 v2.0.4
 	github.com/mdp/qrterminal v1.0.1
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 	golang.org/x/net v0.0.0-20210410081132-afb366fc7cd1 // indirect
-	golang.org/x/sys v0.0.0-20210414055047-fe65e336abe0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
+	rsc.io/qr v0.2.0
 )
@@ -1,12 +1,16 @@
 package hauk
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
 )
 
 type client struct {
@@ -16,32 +20,52 @@ type client struct {
 
 // Client is a client to the Hauk REST API
 type Client interface {
-	CreateSession() (Session, error)
+	CreateSession(options SessionOptions) (Session, error)
 	StopSession(sid string) error
-	PostLocation(sid string, params url.Values) error
+	PostLocation(session Session, params url.Values) error
 }
 
 // New creates a new instance on a hauk client
 func New(config Config) Client {
-	httpClient := http.Client{}
+	httpClient := http.Client{Timeout: config.Timeout}
 	return &client{config: config, httpClient: httpClient}
 }
 
 // CreateSession attempts to create a new hauk session for a given device
-func (t *client) CreateSession() (Session, error) {
+func (t *client) CreateSession(options SessionOptions) (Session, error) {
 	var session Session
+	duration := t.config.Duration
+	if options.Duration > 0 {
+		duration = int(options.Duration.Seconds())
+	}
+	interval := t.config.Interval
+	if options.Interval > 0 {
+		interval = int(options.Interval.Seconds())
+	}
 	params := url.Values{
-		"dur": {strconv.Itoa(t.config.Duration)},
-		"int": {strconv.Itoa(t.config.Interval)},
+		"dur": {strconv.Itoa(duration)},
+		"int": {strconv.Itoa(interval)},
 		"usr": {t.config.User},
 		"pwd": {t.config.Password},
 	}
-	response, err := t.httpClient.PostForm(t.formatURL(EndpointCreate), params)
 
+	// End-to-end encryption: only the salt is sent, the password never leaves hauk-snitch
+	if options.E2EPassword != "" {
+		salt, err := generateSalt()
+		if err != nil {
+			return session, err
+		}
+		params.Set("e2e", "1")
+		params.Set("salt", base64.StdEncoding.EncodeToString(salt))
+		session.E2EKey = deriveKey(options.E2EPassword, salt)
+	}
+
+	response, err := t.post(EndpointCreate, params)
 	err = getPostError(response, err, "posting session")
 	if err != nil {
 		return session, err
 	}
+	defer response.Body.Close()
 
 	body, err := getBodyString(response)
 	if err != nil {
@@ -64,29 +88,40 @@ func (t *client) StopSession(sid string) error {
 	params.Add("sid", sid)
 
 	// Send
-	response, err := t.httpClient.PostForm(t.formatURL(EndpointStop), params)
+	response, err := t.post(EndpointStop, params)
 	err = getPostError(response, err, "stopping session")
 	if err != nil {
 		return err
 	}
-
-	return err
+	return response.Body.Close()
 
 }
 
-// PostLocation sends a new location for the given device.
-// If no previous session can be found, a new one will be created
-func (t *client) PostLocation(sid string, params url.Values) error {
+// PostLocation sends a new location to the given session.
+// If the session is end-to-end encrypted, all location values are encrypted before sending.
+func (t *client) PostLocation(session Session, params url.Values) error {
+
+	// Encrypt
+	if session.IsE2E() {
+		encryptedParams, err := encryptParams(session.E2EKey, params)
+		if err != nil {
+			return err
+		}
+		params = encryptedParams
+	} else {
+		params = copyParams(params)
+	}
 
 	// Add sid
-	params.Add("sid", sid)
+	params.Add("sid", session.SID)
 
 	// Send
-	response, err := t.httpClient.PostForm(t.formatURL(EndpointPost), params)
+	response, err := t.post(EndpointPost, params)
 	err = getPostError(response, err, "posting location")
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 	// Parse body
 	body, err := getBodyString(response)
 	if err != nil {
@@ -101,6 +136,19 @@ func (t *client) PostLocation(sid string, params url.Values) error {
 
 }
 
+// post sends the form to the endpoint, recording latency and status code
+func (t *client) post(endpoint string, params url.Values) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.httpClient.PostForm(t.formatURL(endpoint), params)
+	metrics.HaukRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.HaukRequests.WithLabelValues(endpoint, metrics.StatusError).Inc()
+	} else {
+		metrics.HaukRequests.WithLabelValues(endpoint, strconv.Itoa(response.StatusCode)).Inc()
+	}
+	return response, err
+}
+
 func (t *client) formatURL(endpoint string) string {
 	var protocol string
 	if t.config.IsTLS {
@@ -111,6 +159,14 @@ func (t *client) formatURL(endpoint string) string {
 	return fmt.Sprintf("%s://%s:%d/%s", protocol, t.config.Host, t.config.Port, endpoint)
 }
 
+func copyParams(params url.Values) url.Values {
+	paramsCopy := url.Values{}
+	for key, values := range params {
+		paramsCopy[key] = append([]string(nil), values...)
+	}
+	return paramsCopy
+}
+
 func getBodyString(response *http.Response) (string, error) {
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
@@ -119,11 +175,21 @@ func getBodyString(response *http.Response) (string, error) {
 	return string(body), err
 }
 
+// getPostError returns an UnreachableError for transport errors and server errors, which are worth retrying.
+// Other unexpected status codes mean the request was rejected, retrying it would fail again.
+// The body of a failed response is closed.
 func getPostError(response *http.Response, err error, action string) error {
+	// Includes timeouts
 	if err != nil {
-		return fmt.Errorf("Error while %s: %w", action, err)
-	} else if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("Server did not accept %s ( StatusCode = %d", action, response.StatusCode)
+		return &UnreachableError{Err: fmt.Errorf("Error while %s: %w", action, err)}
+	}
+	if response.StatusCode == http.StatusOK {
+		return nil
 	}
-	return nil
+	response.Body.Close()
+	err = fmt.Errorf("Server did not accept %s (status code %d)", action, response.StatusCode)
+	if response.StatusCode >= http.StatusInternalServerError {
+		return &UnreachableError{Err: err}
+	}
+	return err
 }
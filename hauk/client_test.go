@@ -0,0 +1,105 @@
+package hauk
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startServer(t *testing.T, status int, body string) Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return New(Config{Host: host, Port: portNumber})
+}
+
+func TestPostLocation(t *testing.T) {
+	// given
+	client := startServer(t, http.StatusOK, "OK")
+
+	// when
+	err := client.PostLocation(Session{SID: "session"}, url.Values{})
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestPostLocation_SessionExpired(t *testing.T) {
+	// given
+	client := startServer(t, http.StatusOK, "Session expired!")
+
+	// when
+	err := client.PostLocation(Session{SID: "session"}, url.Values{})
+
+	// then
+	var sessionExpiredError *SessionExpiredError
+	assert.ErrorAs(t, err, &sessionExpiredError)
+}
+
+func TestPostLocation_ServerError(t *testing.T) {
+	// given
+	client := startServer(t, http.StatusServiceUnavailable, "")
+
+	// when
+	err := client.PostLocation(Session{SID: "session"}, url.Values{})
+
+	// then: worth retrying
+	var unreachableError *UnreachableError
+	assert.ErrorAs(t, err, &unreachableError)
+}
+
+func TestPostLocation_Rejected(t *testing.T) {
+	// given
+	client := startServer(t, http.StatusForbidden, "")
+
+	// when
+	err := client.PostLocation(Session{SID: "session"}, url.Values{})
+
+	// then: rejected, not worth retrying
+	var unreachableError *UnreachableError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &unreachableError))
+}
+
+func TestPostLocation_Timeout(t *testing.T) {
+	// given: server which never responds
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(hang) })
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	client := New(Config{Host: host, Port: portNumber, Timeout: 50 * time.Millisecond})
+
+	// when
+	err := client.PostLocation(Session{SID: "session"}, url.Values{})
+
+	// then: worth retrying
+	var unreachableError *UnreachableError
+	assert.ErrorAs(t, err, &unreachableError)
+}
+
+func TestPostLocation_Unreachable(t *testing.T) {
+	// given: nothing listening
+	client := New(Config{Host: "127.0.0.1", Port: 1})
+
+	// when
+	err := client.PostLocation(Session{SID: "session"}, url.Values{})
+
+	// then
+	var unreachableError *UnreachableError
+	assert.ErrorAs(t, err, &unreachableError)
+}
@@ -1,5 +1,7 @@
 package hauk
 
+import "time"
+
 // Config for hauk backend
 type Config struct {
 	Host        string
@@ -10,4 +12,6 @@ type Config struct {
 	IsAnonymous bool
 	Duration    int
 	Interval    int
+	// Timeout of a request, a server not answering in time is treated as unreachable
+	Timeout time.Duration
 }
@@ -38,3 +38,15 @@ const ParamAccuracy string = "acc"
 
 // ParamVelocity is the key for the parameter "velocity"
 const ParamVelocity string = "spd"
+
+// ParamProvider is the key for the parameter "provider" (accuracy mode), 0 is fine and 1 is coarse
+const ParamProvider string = "prv"
+
+// ParamBattery is the key for the parameter "battery level" in percent
+const ParamBattery string = "bat"
+
+// ParamCharging is the key for the parameter "charging", 1 while charging, 0 otherwise
+const ParamCharging string = "chg"
+
+// ParamIV is the key for the parameter "initialization vector" of end-to-end encrypted locations
+const ParamIV string = "iv"
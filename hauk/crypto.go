@@ -0,0 +1,61 @@
+package hauk
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Parameters used by the Hauk clients and frontend for end-to-end encryption
+const e2eIterations = 65536
+const e2eKeyLength = 32
+const e2eSaltLength = 32
+
+func generateSalt() ([]byte, error) {
+	salt := make([]byte, e2eSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("Could not generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+func deriveKey(password string, salt []byte) []byte {
+	return pbkdf2.Key([]byte(password), salt, e2eIterations, e2eKeyLength, sha1.New)
+}
+
+// encryptParams encrypts every value of params with AES-CBC using a fresh IV, which is added as parameter "iv"
+func encryptParams(key []byte, params url.Values) (url.Values, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create cipher: %w", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err = rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("Could not generate IV: %w", err)
+	}
+
+	encryptedParams := url.Values{}
+	for paramKey, values := range params {
+		for _, value := range values {
+			plaintext := pad([]byte(value), aes.BlockSize)
+			ciphertext := make([]byte, len(plaintext))
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+			encryptedParams.Add(paramKey, base64.StdEncoding.EncodeToString(ciphertext))
+		}
+	}
+	encryptedParams.Set(ParamIV, base64.StdEncoding.EncodeToString(iv))
+	return encryptedParams, nil
+}
+
+// pad applies PKCS#7 padding
+func pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	return append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
@@ -0,0 +1,40 @@
+package hauk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptParams(t *testing.T) {
+	// given
+	salt, err := generateSalt()
+	assert.NoError(t, err)
+	key := deriveKey("secret", salt)
+	params := url.Values{ParamLatitude: {"47.5968792"}, ParamLongitude: {"12.9540961"}}
+
+	// when
+	encryptedParams, err := encryptParams(key, params)
+
+	// then: every value can be decrypted using the IV
+	assert.NoError(t, err)
+	iv, err := base64.StdEncoding.DecodeString(encryptedParams.Get(ParamIV))
+	assert.NoError(t, err)
+	assert.Equal(t, "47.5968792", decrypt(t, key, iv, encryptedParams.Get(ParamLatitude)))
+	assert.Equal(t, "12.9540961", decrypt(t, key, iv, encryptedParams.Get(ParamLongitude)))
+}
+
+func decrypt(t *testing.T, key []byte, iv []byte, value string) string {
+	ciphertext, err := base64.StdEncoding.DecodeString(value)
+	assert.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	return string(plaintext[:len(plaintext)-padding])
+}
@@ -4,3 +4,12 @@ package hauk
 type SessionExpiredError struct{}
 
 func (t *SessionExpiredError) Error() string { return "Session expired" }
+
+// UnreachableError signals that the hauk server could not be reached or failed with a server error
+type UnreachableError struct {
+	Err error
+}
+
+func (t *UnreachableError) Error() string { return t.Err.Error() }
+
+func (t *UnreachableError) Unwrap() error { return t.Err }
@@ -1,8 +1,26 @@
 package hauk
 
+import "time"
+
 // Session represents a hauk session
 type Session struct {
-	ID  string
-	SID string
-	URL string
+	ID     string
+	SID    string
+	URL    string
+	E2EKey []byte
+}
+
+// IsE2E returns true if locations of this session are end-to-end encrypted
+func (t Session) IsE2E() bool {
+	return len(t.E2EKey) > 0
+}
+
+// SessionOptions are the options for creating a new hauk session
+type SessionOptions struct {
+	// E2EPassword enables end-to-end encryption of the session if not empty
+	E2EPassword string
+	// Duration overrides the configured session duration if not zero
+	Duration time.Duration
+	// Interval overrides the configured location interval if not zero
+	Interval time.Duration
 }
@@ -0,0 +1,17 @@
+package ingest
+
+// Config holds the configuration of the OwnTracks HTTP endpoint
+type Config struct {
+	Enabled     bool
+	Host        string
+	Port        int
+	Path        string
+	TopicPrefix string
+	Users       []User
+}
+
+// User is an OwnTracks user allowed to post locations via HTTP
+type User struct {
+	User     string
+	Password string
+}
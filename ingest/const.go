@@ -0,0 +1,10 @@
+package ingest
+
+// HeaderUser is the header OwnTracks uses to send the user name
+const HeaderUser string = "X-Limit-U"
+
+// HeaderDevice is the header OwnTracks uses to send the device id
+const HeaderDevice string = "X-Limit-D"
+
+// maxPayloadSize limits the size of a request body
+const maxPayloadSize int64 = 1 << 20
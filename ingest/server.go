@@ -0,0 +1,137 @@
+package ingest
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// Server accepts locations posted by OwnTracks in HTTP mode
+type Server struct {
+	events     chan source.Event
+	config     Config
+	parser     *mqtt.PayloadParser
+	httpServer *http.Server
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// New creates a new OwnTracks HTTP endpoint
+func New(config Config, parser *mqtt.PayloadParser) *Server {
+	server := &Server{config: config, parser: parser, events: make(chan source.Event), done: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.Handle(config.Path, server)
+	server.httpServer = &http.Server{Addr: fmt.Sprintf("%s:%d", config.Host, config.Port), Handler: mux}
+	return server
+}
+
+// Events returns the events posted by OwnTracks
+func (t *Server) Events() <-chan source.Event {
+	return t.events
+}
+
+// ListenAndServe starts serving the endpoint, it blocks until the server fails or is closed
+func (t *Server) ListenAndServe() error {
+	log.Printf("Accepting OwnTracks HTTP posts on %s%s\n", t.httpServer.Addr, t.config.Path)
+	err := t.httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops the server and closes the events channel
+func (t *Server) Close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		t.httpServer.Shutdown(ctx)
+		close(t.events)
+	})
+}
+
+// ServeHTTP converts an OwnTracks HTTP post into an event with the topic <prefix>/<user>/<device>
+func (t *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, password, ok := request.BasicAuth()
+	if !ok || !t.isAuthorized(user, password) {
+		writer.Header().Set("WWW-Authenticate", `Basic realm="hauk-snitch"`)
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Users may only post for themselves
+	limitUser := request.Header.Get(HeaderUser)
+	if limitUser == "" {
+		limitUser = user
+	} else if limitUser != user {
+		http.Error(writer, "Forbidden", http.StatusForbidden)
+		return
+	}
+	device := request.Header.Get(HeaderDevice)
+	if device == "" {
+		http.Error(writer, fmt.Sprintf("Header %s is missing", HeaderDevice), http.StatusBadRequest)
+		return
+	}
+	// The device is a single topic level, it must not add levels or wildcards
+	if strings.ContainsAny(device, "/+#") {
+		http.Error(writer, fmt.Sprintf("Header %s must not contain /, + or #", HeaderDevice), http.StatusBadRequest)
+		return
+	}
+	topic := fmt.Sprintf("%s/%s/%s", t.config.TopicPrefix, limitUser, device)
+	metrics.MessagesReceived.WithLabelValues(topic).Inc()
+
+	payload, err := ioutil.ReadAll(io.LimitReader(request.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(writer, "Could not read body", http.StatusBadRequest)
+		return
+	}
+	event, err := t.parser.ParseEvent(topic, payload)
+	var unsupportedTypeError *mqtt.UnsupportedTypeError
+	if errors.As(err, &unsupportedTypeError) {
+		// OwnTracks retries failed posts, so other types are acknowledged but ignored
+		log.Printf("Skipping message on %s: %v\n", topic, err)
+	} else if err != nil {
+		metrics.MessagesUnparsable.WithLabelValues(topic).Inc()
+		log.Printf("Could not parse message on %s, skipping: %v\n", topic, err)
+		http.Error(writer, "Could not parse body", http.StatusBadRequest)
+		return
+	} else {
+		select {
+		case t.events <- event:
+		case <-t.done:
+			http.Error(writer, "Shutting down", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	// OwnTracks expects a JSON array, which may contain messages for the device
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write([]byte("[]"))
+}
+
+func (t *Server) isAuthorized(user string, password string) bool {
+	for _, configUser := range t.config.Users {
+		if configUser.User == user && subtle.ConstantTimeCompare([]byte(configUser.Password), []byte(password)) == 1 {
+			return true
+		}
+	}
+	return false
+}
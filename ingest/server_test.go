@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+const locationPayload = `{"_type":"location","lat":1.5,"lon":2.5,"tst":1234}`
+
+func newTestServer() *Server {
+	config := Config{Path: "/pub", TopicPrefix: "owntracks", Users: []User{{User: "alice", Password: "secret"}}}
+	return New(config, mqtt.NewPayloadParser(mqtt.Config{}))
+}
+
+func newRequest(user string, password string, limitUser string, device string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/pub", strings.NewReader(locationPayload))
+	request.SetBasicAuth(user, password)
+	if limitUser != "" {
+		request.Header.Set(HeaderUser, limitUser)
+	}
+	if device != "" {
+		request.Header.Set(HeaderDevice, device)
+	}
+	return request
+}
+
+func TestServeHTTP_Unauthorized(t *testing.T) {
+	// given
+	server := newTestServer()
+	response := httptest.NewRecorder()
+
+	// when
+	server.ServeHTTP(response, newRequest("alice", "wrong", "alice", "phone"))
+
+	// then
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestServeHTTP_ForbiddenForOtherUser(t *testing.T) {
+	// given
+	server := newTestServer()
+	response := httptest.NewRecorder()
+
+	// when
+	server.ServeHTTP(response, newRequest("alice", "secret", "bob", "phone"))
+
+	// then
+	assert.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestServeHTTP_MissingDevice(t *testing.T) {
+	// given
+	server := newTestServer()
+	response := httptest.NewRecorder()
+
+	// when
+	server.ServeHTTP(response, newRequest("alice", "secret", "alice", ""))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestServeHTTP_InvalidDevice(t *testing.T) {
+	for _, device := range []string{"phone/cmd", "+", "#"} {
+		// given
+		server := newTestServer()
+		response := httptest.NewRecorder()
+
+		// when
+		server.ServeHTTP(response, newRequest("alice", "secret", "alice", device))
+
+		// then
+		assert.Equal(t, http.StatusBadRequest, response.Code, device)
+	}
+}
+
+func TestServeHTTP_Location(t *testing.T) {
+	// given
+	server := newTestServer()
+	response := httptest.NewRecorder()
+	events := make(chan source.Event, 1)
+	go func() {
+		events <- <-server.Events()
+	}()
+
+	// when
+	server.ServeHTTP(response, newRequest("alice", "secret", "alice", "phone"))
+
+	// then
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "[]", response.Body.String())
+	event := <-events
+	assert.Equal(t, "owntracks/alice/phone", event.Topic)
+	assert.Equal(t, source.TypeLocation, event.Type)
+	assert.Equal(t, 1.5, event.Location.Latitude)
+}
+
+func TestServeHTTP_UnsupportedTypeIsAcknowledged(t *testing.T) {
+	// given: a waypoint, which is not forwarded
+	server := newTestServer()
+	response := httptest.NewRecorder()
+	request := newRequest("alice", "secret", "alice", "phone")
+	request.Body = ioutil.NopCloser(strings.NewReader(`{"_type":"waypoint"}`))
+
+	// when
+	server.ServeHTTP(response, request)
+
+	// then
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "[]", response.Body.String())
+}
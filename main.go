@@ -1,21 +1,30 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 
+	"github.com/tuffnerdstuff/hauk-snitch/api"
+	"github.com/tuffnerdstuff/hauk-snitch/command"
 	"github.com/tuffnerdstuff/hauk-snitch/config"
 	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/ingest"
 	m "github.com/tuffnerdstuff/hauk-snitch/mapper"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
 	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
 	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
 )
 
 var mqttClient *mqtt.Client
+var ingestServer *ingest.Server
 var haukClient hauk.Client
-var notifier notification.Notifier
-var mapper m.Mapper
+var notifier *notification.Dispatcher
+var mapper *m.Mapper
+var sessionStore store.Store
 
 func main() {
 	handleInterrupt()
@@ -23,8 +32,17 @@ func main() {
 
 	initHaukClient()
 	initMqttClient()
+	initIngestServer()
+	initMetrics()
+	connectMqttClient()
 	initNotifier()
+	initStore()
 	initMapper()
+	initAPI()
+	initCommands()
+
+	mapper.Run(source.Merge(getSources()...))
+	notifier.Close()
 
 }
 
@@ -37,12 +55,66 @@ func handleInterrupt() {
 		if mqttClient != nil {
 			mqttClient.Disconnect()
 		}
+		if ingestServer != nil {
+			ingestServer.Close()
+		}
+	}()
+}
+
+func initMetrics() {
+	metricsConfig := config.GetMetricsConfig()
+	if !metricsConfig.Enabled {
+		return
+	}
+	healthChecks := map[string]metrics.HealthCheck{}
+	if mqttClient != nil {
+		healthChecks["mqtt"] = mqttClient.CheckHealth
+	}
+	go func() {
+		log.Fatalf("Metrics server failed: %v", metrics.Serve(metricsConfig, healthChecks))
 	}()
 }
 
 func initMqttClient() {
-	mqttClient = mqtt.New(config.GetMqttConfig())
-	mqttClient.Connect()
+	mqttConfig := config.GetMqttConfig()
+	if !mqttConfig.Enabled {
+		return
+	}
+	mqttClient = mqtt.New(mqttConfig)
+}
+
+func connectMqttClient() {
+	if mqttClient != nil {
+		mqttClient.Connect()
+	}
+}
+
+func initIngestServer() {
+	ingestConfig := config.GetIngestConfig()
+	if !ingestConfig.Enabled {
+		return
+	}
+	// OwnTracks encryption keys are shared with mqtt
+	ingestServer = ingest.New(ingestConfig, mqtt.NewPayloadParser(config.GetMqttConfig()))
+	go func() {
+		if err := ingestServer.ListenAndServe(); err != nil {
+			log.Fatalf("OwnTracks HTTP server failed: %v", err)
+		}
+	}()
+}
+
+func getSources() []source.Source {
+	var sources []source.Source
+	if mqttClient != nil {
+		sources = append(sources, mqttClient)
+	}
+	if ingestServer != nil {
+		sources = append(sources, ingestServer)
+	}
+	if len(sources) == 0 {
+		panic(fmt.Errorf("Config error: neither mqtt nor http is enabled"))
+	}
+	return sources
 }
 
 func initHaukClient() {
@@ -50,10 +122,51 @@ func initHaukClient() {
 }
 
 func initNotifier() {
-	notifier = notification.New(config.GetNotificationConfig())
+	var err error
+	notifier, err = notification.New(config.GetNotificationConfig())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func initStore() {
+	var err error
+	sessionStore, err = store.New(config.GetStoreConfig())
+	if err != nil {
+		panic(err)
+	}
 }
 
 func initMapper() {
-	mapper = m.New(config.GetMapperConfig(), haukClient, notifier)
-	mapper.Run(mqttClient.Messages)
+	// mqttClient may be nil, which must not end up as non-nil interface
+	var publisher m.Publisher
+	if mqttClient != nil {
+		publisher = mqttClient
+	}
+	mapper = m.New(config.GetMapperConfig(), haukClient, notifier, sessionStore, publisher)
+}
+
+func initAPI() {
+	apiConfig := config.GetAPIConfig()
+	if !apiConfig.Enabled {
+		return
+	}
+	if apiConfig.Token == "" {
+		panic(fmt.Errorf("Config error: api.token must be set if the API is enabled"))
+	}
+	apiServer := api.New(apiConfig, mapper)
+	go func() {
+		log.Fatalf("API server failed: %v", apiServer.ListenAndServe())
+	}()
+}
+
+func initCommands() {
+	commandConfig := config.GetCommandConfig()
+	if !commandConfig.Enabled {
+		return
+	}
+	if mqttClient == nil {
+		panic(fmt.Errorf("Config error: commands require mqtt to be enabled"))
+	}
+	command.New(commandConfig, mapper, mqttClient).Start()
 }
@@ -1,8 +1,52 @@
 package mapper
 
+import (
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+)
+
 // Config holds the mapper configuration
 type Config struct {
 	SessionStartAuto   bool
 	SessionStopAuto    bool
 	SessionStartManual bool
+	SessionDuration    time.Duration
+	// SilentAfter is the time without locations after which a device with a session is reported as silent, 0 disables
+	SilentAfter  time.Duration
+	E2EPassword  string
+	E2EPasswords []E2EPassword
+	Queue        QueueConfig
+	Geofence     GeofenceConfig
+	Devices      []DeviceConfig
+	Publish      PublishConfig
+	Stale        StaleConfig
+	Filter       FilterConfig
+	RateLimit    RateLimitConfig
+	PrivacyZones []PrivacyZone
+}
+
+// QueueConfig holds the configuration of the queue buffering locations while Hauk is unreachable
+type QueueConfig struct {
+	Enabled      bool
+	Mode         string
+	Size         int
+	RetryInitial time.Duration
+	RetryMax     time.Duration
+	Path         string
+}
+
+// E2EPassword is the Hauk end-to-end encryption password for all topics matching the topic pattern
+type E2EPassword struct {
+	Topic    string
+	Password string
+}
+
+func (t *Config) e2ePasswordForTopic(topic string) string {
+	for _, e2ePassword := range t.E2EPasswords {
+		if mqtt.MatchTopic(e2ePassword.Topic, topic) {
+			return e2ePassword.Password
+		}
+	}
+	return t.E2EPassword
 }
@@ -0,0 +1,32 @@
+package mapper
+
+// QueueModeAll keeps all undelivered locations (up to the queue size) and delivers them in order
+const QueueModeAll string = "all"
+
+// QueueModeLatest keeps only the most recent undelivered location of each topic
+const QueueModeLatest string = "latest"
+
+// BacklogDrop drops locations older than the maximum age
+const BacklogDrop string = "drop"
+
+// BacklogTrail posts locations older than the maximum age to the current session only,
+// they neither start sessions nor trigger geofences
+const BacklogTrail string = "trail"
+
+// FilterModeDrop drops locations which do not pass the filter
+const FilterModeDrop string = "drop"
+
+// FilterModeSmooth corrects locations which do not pass the filter
+const FilterModeSmooth string = "smooth"
+
+// PrivacyModeSuppress drops locations inside the privacy zone
+const PrivacyModeSuppress string = "suppress"
+
+// PrivacyModeEdge moves locations inside the privacy zone to its closest border
+const PrivacyModeEdge string = "edge"
+
+// PrivacyModeCentroid moves locations inside the privacy zone to its center
+const PrivacyModeCentroid string = "centroid"
+
+// PrivacyModeGrid coarsens locations inside the privacy zone to the center of a grid cell
+const PrivacyModeGrid string = "grid"
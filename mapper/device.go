@@ -0,0 +1,139 @@
+package mapper
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+)
+
+// DeviceConfig overrides settings for all topics matching the topic pattern.
+// Nil values are not overridden.
+type DeviceConfig struct {
+	Topic              string
+	Name               string
+	SessionStartAuto   *bool
+	SessionStopAuto    *bool
+	SessionStartManual *bool
+	SessionDuration    *time.Duration
+	SessionInterval    *time.Duration
+	SilentAfter        *time.Duration
+	E2EPassword        *string
+	NotifyChannels     []string
+	NotifyEmailTo      []string
+	// NotifyRecipients override the recipients per channel name
+	NotifyRecipients map[string][]string
+	MaxAccuracy      *float64
+	MinDistance      *float64
+	MaxSpeed         *float64
+	FilterMode       *string
+}
+
+// device holds the effective settings of a device
+type device struct {
+	topic              string
+	sessionStartAuto   bool
+	sessionStopAuto    bool
+	sessionStartManual bool
+	sessionDuration    time.Duration
+	sessionInterval    time.Duration
+	silentAfter        time.Duration
+	e2ePassword        string
+	notification       notification.Device
+	filter             FilterConfig
+}
+
+// device resolves the settings for the topic, applying all matching device overrides.
+// Topic patterns are matched case-insensitively, more specific patterns take precedence.
+func (t *Config) device(topic string) device {
+	resolved := device{
+		topic:              topic,
+		sessionStartAuto:   t.SessionStartAuto,
+		sessionStopAuto:    t.SessionStopAuto,
+		sessionStartManual: t.SessionStartManual,
+		sessionDuration:    t.SessionDuration,
+		silentAfter:        t.SilentAfter,
+		e2ePassword:        t.e2ePasswordForTopic(topic),
+		notification:       notification.Device{Topic: topic},
+		filter:             t.Filter,
+	}
+
+	for _, deviceConfig := range t.matchingDevices(topic) {
+		if deviceConfig.Name != "" {
+			resolved.notification.Name = deviceConfig.Name
+		}
+		if deviceConfig.SessionStartAuto != nil {
+			resolved.sessionStartAuto = *deviceConfig.SessionStartAuto
+		}
+		if deviceConfig.SessionStopAuto != nil {
+			resolved.sessionStopAuto = *deviceConfig.SessionStopAuto
+		}
+		if deviceConfig.SessionStartManual != nil {
+			resolved.sessionStartManual = *deviceConfig.SessionStartManual
+		}
+		if deviceConfig.SessionDuration != nil {
+			resolved.sessionDuration = *deviceConfig.SessionDuration
+		}
+		if deviceConfig.SessionInterval != nil {
+			resolved.sessionInterval = *deviceConfig.SessionInterval
+		}
+		if deviceConfig.SilentAfter != nil {
+			resolved.silentAfter = *deviceConfig.SilentAfter
+		}
+		if deviceConfig.E2EPassword != nil {
+			resolved.e2ePassword = *deviceConfig.E2EPassword
+		}
+		if deviceConfig.NotifyChannels != nil {
+			resolved.notification.Channels = deviceConfig.NotifyChannels
+		}
+		if deviceConfig.NotifyEmailTo != nil {
+			resolved.notification.EmailTo = deviceConfig.NotifyEmailTo
+		}
+		for channel, recipients := range deviceConfig.NotifyRecipients {
+			if resolved.notification.Recipients == nil {
+				resolved.notification.Recipients = make(map[string][]string)
+			}
+			resolved.notification.Recipients[strings.ToLower(channel)] = recipients
+		}
+		if deviceConfig.MaxAccuracy != nil {
+			resolved.filter.MaxAccuracy = *deviceConfig.MaxAccuracy
+		}
+		if deviceConfig.MinDistance != nil {
+			resolved.filter.MinDistance = *deviceConfig.MinDistance
+		}
+		if deviceConfig.MaxSpeed != nil {
+			resolved.filter.MaxSpeed = *deviceConfig.MaxSpeed
+		}
+		if deviceConfig.FilterMode != nil {
+			resolved.filter.Mode = *deviceConfig.FilterMode
+		}
+	}
+	return resolved
+}
+
+// matchingDevices returns the device configs matching the topic, least specific first
+func (t *Config) matchingDevices(topic string) []DeviceConfig {
+	var matching []DeviceConfig
+	for _, deviceConfig := range t.Devices {
+		if mqtt.MatchTopic(strings.ToLower(deviceConfig.Topic), strings.ToLower(topic)) {
+			matching = append(matching, deviceConfig)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return specificity(matching[i].Topic) < specificity(matching[j].Topic)
+	})
+	return matching
+}
+
+// specificity is the number of topic levels of the pattern which are not wildcards
+func specificity(pattern string) int {
+	count := 0
+	for _, level := range strings.Split(pattern, "/") {
+		if level != "+" && level != "#" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,119 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestConfig_Device(t *testing.T) {
+	// given: global settings, an override for all phones of "kid" and one for a specific phone
+	disabled := false
+	long := 24 * time.Hour
+	short := 10 * time.Minute
+	config := Config{
+		SessionStartAuto: true,
+		SessionStopAuto:  true,
+		SessionDuration:  time.Hour,
+		Devices: []DeviceConfig{
+			{Topic: "owntracks/kid/phone", Name: "Kid", SessionDuration: &short},
+			{Topic: "owntracks/kid/+", SessionDuration: &long, SessionStopAuto: &disabled, NotifyEmailTo: []string{"mom@example.com", "dad@example.com"}},
+		},
+	}
+
+	// when
+	kid := config.device("owntracks/Kid/phone")
+	other := config.device("owntracks/dude/phone")
+
+	// then: more specific override wins, others are merged, matching is case-insensitive
+	assert.Equal(t, "Kid", kid.notification.Name)
+	assert.Equal(t, short, kid.sessionDuration)
+	assert.False(t, kid.sessionStopAuto)
+	assert.True(t, kid.sessionStartAuto)
+	assert.Equal(t, []string{"mom@example.com", "dad@example.com"}, kid.notification.EmailTo)
+
+	// then: other devices use global settings
+	assert.Equal(t, "owntracks/dude/phone", other.notification.DisplayName())
+	assert.Equal(t, time.Hour, other.sessionDuration)
+	assert.True(t, other.sessionStopAuto)
+	assert.Nil(t, other.notification.EmailTo)
+}
+
+func TestConfig_DeviceRecipients(t *testing.T) {
+	// given: recipients for all devices of "kid" and for a specific phone
+	config := Config{
+		Devices: []DeviceConfig{
+			{Topic: "owntracks/kid/phone", NotifyRecipients: map[string][]string{"Family": {"kids-phone"}}},
+			{Topic: "owntracks/kid/+", NotifyRecipients: map[string][]string{"family": {"kids"}, "smtp": {"school@example.com"}}},
+		},
+	}
+
+	// when
+	kid := config.device("owntracks/kid/phone")
+	other := config.device("owntracks/dude/phone")
+
+	// then: more specific recipients win per channel, channel names are lowercase
+	assert.Equal(t, map[string][]string{"family": {"kids-phone"}, "smtp": {"school@example.com"}}, kid.notification.Recipients)
+	assert.Nil(t, other.notification.Recipients)
+}
+
+func TestRun_DeviceOverrides(t *testing.T) {
+	// given: locations of two devices
+	kidLocation := createValidLocation()
+	dudeLocation := createValidLocation()
+	events := make(chan source.Event, 2)
+	events <- source.Event{Topic: "owntracks/kid/phone", Type: source.TypeLocation, Location: kidLocation}
+	events <- source.Event{Topic: "owntracks/dude/phone", Type: source.TypeLocation, Location: dudeLocation}
+	close(events)
+
+	// given: kid gets a long session, dude has autostart disabled
+	disabled := false
+	long := 24 * time.Hour
+	interval := 5 * time.Second
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{Duration: long, Interval: interval}).Return(hauk.Session{SID: "kidSession", URL: "kidURL"}, nil).Once()
+	haukClient.On("PostLocation", "kidSession", getExpectedLocationValues(kidLocation)).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "owntracks/kid/phone", Name: "Kid"}, "kidURL").Once()
+
+	// when
+	sessions := store.NewMemory()
+	mapper := New(Config{
+		SessionStartAuto: true,
+		SessionDuration:  time.Hour,
+		Devices: []DeviceConfig{
+			{Topic: "owntracks/kid/phone", Name: "Kid", SessionDuration: &long, SessionInterval: &interval},
+			{Topic: "owntracks/dude/+", SessionStartAuto: &disabled},
+		},
+	}, haukClient, notifier, sessions, nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+	entry, _ := sessions.Get("owntracks/kid/phone")
+	assert.Equal(t, long, entry.Expires.Sub(entry.Created))
+}
+
+func TestConfig_DeviceFilter(t *testing.T) {
+	// given: a global filter and an override for a car
+	maxSpeed := 250 / 3.6
+	smooth := FilterModeSmooth
+	config := Config{
+		Filter:  FilterConfig{MaxAccuracy: 100, MaxSpeed: 50, Mode: FilterModeDrop},
+		Devices: []DeviceConfig{{Topic: "owntracks/+/car", MaxSpeed: &maxSpeed, FilterMode: &smooth}},
+	}
+
+	// when
+	car := config.device("owntracks/dude/car")
+	phone := config.device("owntracks/dude/phone")
+
+	// then
+	assert.Equal(t, FilterConfig{MaxAccuracy: 100, MaxSpeed: maxSpeed, Mode: FilterModeSmooth}, car.filter)
+	assert.Equal(t, config.Filter, phone.filter)
+}
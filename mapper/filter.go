@@ -0,0 +1,95 @@
+package mapper
+
+import (
+	"log"
+	"math"
+
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// FilterConfig holds the configuration of filtering inaccurate, jittering and implausible locations.
+// A value of 0 disables the respective check.
+type FilterConfig struct {
+	// MaxAccuracy is the maximum accuracy radius in meters
+	MaxAccuracy float64
+	// MinDistance is the minimum distance in meters the device has to move since the last posted location
+	MinDistance float64
+	// MaxSpeed is the maximum plausible speed in m/s between the last posted location and the new one
+	MaxSpeed float64
+	// Mode is FilterModeDrop or FilterModeSmooth
+	Mode string
+}
+
+// filterLocation checks the location against the filter settings of the device and the last posted location.
+// Depending on the filter mode, locations which do not pass are either dropped or corrected.
+func (t *Mapper) filterLocation(event source.Event) (source.Event, bool) {
+	filter := t.config.device(event.Topic).filter
+	smooth := filter.Mode == FilterModeSmooth
+	last, posted := t.lastLocations[event.Topic]
+	location := event.Location
+
+	if filter.MaxAccuracy > 0 && location.Accuracy != nil && *location.Accuracy > filter.MaxAccuracy {
+		if !smooth || !posted || last.Accuracy == nil || *last.Accuracy <= 0 {
+			log.Printf("Location of %s is inaccurate (%.0f m), skipping\n", event.Topic, *location.Accuracy)
+			metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonInaccurate).Inc()
+			return event, false
+		}
+		location = weightedAverage(last, location)
+		metrics.LocationsSmoothed.WithLabelValues(event.Topic, metrics.ReasonInaccurate).Inc()
+	}
+	if !posted {
+		event.Location = location
+		return event, true
+	}
+
+	lastPoint := geo.Point{Latitude: last.Latitude, Longitude: last.Longitude}
+	point := geo.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+	distance := geo.Distance(lastPoint, point)
+
+	// Manual locations are always posted, as they may be meant to start a session
+	if filter.MinDistance > 0 && distance < filter.MinDistance && !event.Manual {
+		if !smooth {
+			log.Printf("Location of %s moved only %.0f m, skipping\n", event.Topic, distance)
+			metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonJitter).Inc()
+			return event, false
+		}
+		// Repost the last coordinates, so the session stays up to date without the marker moving
+		location.Latitude = last.Latitude
+		location.Longitude = last.Longitude
+		metrics.LocationsSmoothed.WithLabelValues(event.Topic, metrics.ReasonJitter).Inc()
+	}
+
+	if filter.MaxSpeed > 0 && !location.Time.IsZero() && !last.Time.IsZero() {
+		elapsed := location.Time.Sub(last.Time).Seconds()
+		if elapsed > 0 && distance/elapsed > filter.MaxSpeed {
+			if !smooth {
+				log.Printf("Location of %s implies %.0f m/s, skipping\n", event.Topic, distance/elapsed)
+				metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonImplausible).Inc()
+				return event, false
+			}
+			// Move towards the new location only as far as plausible
+			clamped := geo.Interpolate(lastPoint, point, filter.MaxSpeed*elapsed/distance)
+			location.Latitude = clamped.Latitude
+			location.Longitude = clamped.Longitude
+			metrics.LocationsSmoothed.WithLabelValues(event.Topic, metrics.ReasonImplausible).Inc()
+		}
+	}
+
+	event.Location = location
+	return event, true
+}
+
+// weightedAverage combines the coordinates of both locations weighted by their inverse variance.
+// The accuracy of the result is better than either of them.
+func weightedAverage(last source.Location, location source.Location) source.Location {
+	lastWeight := 1 / (*last.Accuracy * *last.Accuracy)
+	weight := 1 / (*location.Accuracy * *location.Accuracy)
+	total := lastWeight + weight
+	location.Latitude = (last.Latitude*lastWeight + location.Latitude*weight) / total
+	location.Longitude = (last.Longitude*lastWeight + location.Longitude*weight) / total
+	accuracy := math.Sqrt(1 / total)
+	location.Accuracy = &accuracy
+	return location
+}
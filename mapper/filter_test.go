@@ -0,0 +1,102 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestRun_FilterDrop(t *testing.T) {
+	// given: an inaccurate location, one which barely moved, one too far away and a valid one
+	first := createValidLocation()
+	inaccurate := createValidLocation()
+	inaccurate.Time = first.Time.Add(time.Minute)
+	inaccuracy := 800.0
+	inaccurate.Accuracy = &inaccuracy
+	jitter := createValidLocation()
+	jitter.Time = first.Time.Add(2 * time.Minute)
+	jitter.Latitude += 0.0001
+	implausible := createValidLocation()
+	implausible.Time = first.Time.Add(3 * time.Minute)
+	implausible.Latitude += 1
+	valid := createValidLocation()
+	valid.Time = first.Time.Add(4 * time.Minute)
+	valid.Latitude += 0.01
+	events := make(chan source.Event, 5)
+	for _, location := range []source.Location{first, inaccurate, jitter, implausible, valid} {
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	}
+	close(events)
+
+	// given: only first and valid location are posted
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(first)).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(valid)).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Filter:           FilterConfig{MaxAccuracy: 500, MinDistance: 50, MaxSpeed: 200 / 3.6, Mode: FilterModeDrop},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestFilterLocation_Smooth(t *testing.T) {
+	// given: a device with a posted location
+	mapper := New(Config{
+		Filter: FilterConfig{MaxAccuracy: 100, MinDistance: 50, MaxSpeed: 10, Mode: FilterModeSmooth},
+	}, nil, nil, store.NewMemory(), nil)
+	last := createValidLocation()
+	accuracy := 50.0
+	last.Accuracy = &accuracy
+	mapper.lastLocations["whatevs"] = last
+
+	// when: an inaccurate location is received
+	inaccurate := createValidLocation()
+	inaccurate.Time = last.Time.Add(time.Hour)
+	inaccurate.Latitude += 0.01
+	inaccuracy := 3 * accuracy
+	inaccurate.Accuracy = &inaccuracy
+	event, passed := mapper.filterLocation(source.Event{Topic: "whatevs", Location: inaccurate})
+
+	// then: the result is weighted towards the accurate location
+	assert.True(t, passed)
+	assert.InDelta(t, last.Latitude+0.001, event.Location.Latitude, 1e-9)
+	assert.Less(t, *event.Location.Accuracy, *last.Accuracy)
+
+	// when: the device barely moved
+	jitter := createValidLocation()
+	jitter.Time = last.Time.Add(time.Minute)
+	jitter.Latitude += 0.0001
+	event, passed = mapper.filterLocation(source.Event{Topic: "whatevs", Location: jitter})
+
+	// then: the last coordinates are reposted with the new time
+	assert.True(t, passed)
+	assert.Equal(t, last.Latitude, event.Location.Latitude)
+	assert.Equal(t, jitter.Time, event.Location.Time)
+
+	// when: the device moved faster than plausible
+	implausible := createValidLocation()
+	implausible.Time = last.Time.Add(time.Minute)
+	implausible.Latitude += 1
+	event, passed = mapper.filterLocation(source.Event{Topic: "whatevs", Location: implausible})
+
+	// then: the location is moved towards the new one only as far as plausible
+	assert.True(t, passed)
+	lastPoint := geo.Point{Latitude: last.Latitude, Longitude: last.Longitude}
+	point := geo.Point{Latitude: event.Location.Latitude, Longitude: event.Location.Longitude}
+	assert.InDelta(t, 600, geo.Distance(lastPoint, point), 1)
+}
@@ -0,0 +1,124 @@
+package mapper
+
+import (
+	"log"
+
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// GeofenceConfig holds the configuration of the geofence driven session handling
+type GeofenceConfig struct {
+	Enabled           bool
+	Transitions       bool
+	TransitionRegions []string
+	Regions           []Region
+}
+
+// Region is a circular region, sessions are stopped while a device is inside
+type Region struct {
+	Name      string
+	Topic     string
+	Latitude  float64
+	Longitude float64
+	Radius    float64
+}
+
+func (t *GeofenceConfig) isTransitionRegion(name string) bool {
+	if len(t.TransitionRegions) == 0 {
+		return true
+	}
+	for _, transitionRegion := range t.TransitionRegions {
+		if transitionRegion == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *GeofenceConfig) regionContaining(topic string, point geo.Point) (string, bool) {
+	for _, region := range t.Regions {
+		if region.Topic != "" && !mqtt.MatchTopic(region.Topic, topic) {
+			continue
+		}
+		circle := geo.Circle{Center: geo.Point{Latitude: region.Latitude, Longitude: region.Longitude}, Radius: region.Radius}
+		if circle.Contains(point) {
+			return region.Name, true
+		}
+	}
+	return "", false
+}
+
+// handleTransition starts or stops the session of the topic on OwnTracks region enter/leave events
+func (t *Mapper) handleTransition(event source.Event) {
+	if !t.config.Geofence.Enabled || !t.config.Geofence.Transitions {
+		return
+	}
+	if !t.config.Geofence.isTransitionRegion(event.Region) {
+		return
+	}
+	switch event.Type {
+	case source.TypeEnter:
+		t.enterRegion(event.Topic, event.Region)
+	case source.TypeLeave:
+		t.leaveRegion(event.Topic, event.Region)
+	}
+}
+
+// isInsideRegion returns true if the device is inside a region.
+// If the location crosses the border of a configured region, the session is started or stopped.
+func (t *Mapper) isInsideRegion(event source.Event) bool {
+	if !t.config.Geofence.Enabled {
+		return false
+	}
+
+	if len(t.config.Geofence.Regions) > 0 {
+		point := geo.Point{Latitude: event.Location.Latitude, Longitude: event.Location.Longitude}
+		currentRegion, wasInside := t.topicRegionMap[event.Topic]
+		region, isInside := t.config.Geofence.regionContaining(event.Topic, point)
+		if isInside && !wasInside {
+			t.enterRegion(event.Topic, region)
+		} else if !isInside && wasInside {
+			t.leaveRegion(event.Topic, currentRegion)
+		}
+	}
+
+	_, inside := t.topicRegionMap[event.Topic]
+	return inside
+}
+
+func (t *Mapper) enterRegion(topic string, region string) {
+	log.Printf("%s entered region %s", topic, region)
+	t.topicRegionMap[topic] = region
+	t.pauseSession(topic)
+}
+
+// pauseSession stops the session of the topic until a new one is created.
+// It returns false if the topic had no session.
+func (t *Mapper) pauseSession(topic string) bool {
+	entry, sessionExists := t.sessions.Get(topic)
+	if !sessionExists {
+		return false
+	}
+	log.Printf("Stopping session for %s: %v", topic, entry.Session)
+	if err := t.haukClient.StopSession(entry.Session.SID); err != nil {
+		log.Printf("Error while stopping session %+v: %v", entry.Session, err)
+	}
+	if err := t.sessions.Delete(topic); err != nil {
+		log.Printf("Could not remove stopped session for %s: %v", topic, err)
+	}
+	t.unpublishSession(topic)
+	t.notify(notification.EventSessionStopped, topic, entry)
+	return true
+}
+
+func (t *Mapper) leaveRegion(topic string, region string) {
+	log.Printf("%s left region %s, creating session", topic, region)
+	delete(t.topicRegionMap, topic)
+
+	if _, err := t.createNewSessionForTopic(topic); err != nil {
+		log.Printf("%v\n", err.Error())
+	}
+}
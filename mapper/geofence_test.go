@@ -0,0 +1,102 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestRun_GeofenceTransitions(t *testing.T) {
+	// given: leave home, location, enter home, location
+	location1 := createValidLocation()
+	location1.Time = time.Unix(1, 0)
+	location2 := createValidLocation()
+	location2.Time = time.Unix(2, 0)
+	events := make(chan source.Event, 4)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLeave, Region: "Home"}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location1}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeEnter, Region: "Home"}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location2}
+	close(events)
+
+	// given: session is started on leave, stopped on enter, second location is not posted
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location1)).Return(nil).Once()
+	haukClient.On("StopSession", "session").Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+	notifier.On("Notify", notification.EventSessionStopped, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Geofence:         GeofenceConfig{Enabled: true, Transitions: true, TransitionRegions: []string{"Home"}},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_GeofenceTransitionOtherRegionIgnored(t *testing.T) {
+	// given
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLeave, Region: "Work"}
+	close(events)
+	haukClient := new(MockHaukClient)
+	notifier := new(MockNotifier)
+
+	// when
+	mapper := New(Config{
+		Geofence: GeofenceConfig{Enabled: true, Transitions: true, TransitionRegions: []string{"Home"}},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then: nothing happens
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_GeofenceRegions(t *testing.T) {
+	// given: location at home, away and back at home
+	home := createValidLocation()
+	home.Time = time.Unix(1, 0)
+	away := createValidLocation()
+	away.Latitude = 47.7
+	away.Time = time.Unix(2, 0)
+	backHome := createValidLocation()
+	backHome.Time = time.Unix(3, 0)
+	events := make(chan source.Event, 3)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: home}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: away}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: backHome}
+	close(events)
+
+	// given: only the location away is posted
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(away)).Return(nil).Once()
+	haukClient.On("StopSession", "session").Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+	notifier.On("Notify", notification.EventSessionStopped, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Geofence: GeofenceConfig{Enabled: true, Regions: []Region{
+			{Name: "Home", Latitude: home.Latitude, Longitude: home.Longitude, Radius: 100},
+		}},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
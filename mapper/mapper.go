@@ -1,142 +1,457 @@
 package mapper
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/mdp/qrterminal"
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
 	"github.com/tuffnerdstuff/hauk-snitch/hauk"
-	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
 	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
 )
 
-// Mapper orchestrates incoming locations via mqtt and outgoing calls to Hauk
+// Mapper orchestrates incoming locations of all sources and outgoing calls to Hauk
 type Mapper struct {
-	topicSessionMap map[string]hauk.Session
-	haukClient      hauk.Client
-	notifier        notification.Notifier
-	config          Config
-}
-
-type valueMapping struct {
-	haukKey         string
-	mappingFunction func(value interface{}) string
-}
-
-var mqttToHaukKeyMap = map[string]valueMapping{
-	mqtt.ParamAccuracy:  {hauk.ParamAccuracy, nil},
-	mqtt.ParamAltitude:  {hauk.ParamAltitude, nil},
-	mqtt.ParamLatitude:  {hauk.ParamLatitude, nil},
-	mqtt.ParamLongitude: {hauk.ParamLongitude, nil},
-	mqtt.ParamVelocity: {hauk.ParamVelocity, func(value interface{}) string {
-		// km/h -> m/s
-		return fmt.Sprintf("%f", convertToFloat(value)/3.6)
-	}},
-	mqtt.ParamTime: {hauk.ParamTime, func(value interface{}) string {
-		// UNIX epoch float -> int
-		// Hauk Android client also sends float, but formatted differently.
-		// Before converting to int the frontend sometimes did not update.
-		return fmt.Sprintf("%d", int64(convertToFloat(value)))
-	}},
+	mutex      sync.Mutex
+	sessions   store.Store
+	haukClient hauk.Client
+	notifier   notification.Notifier
+	config     Config
+	queue      *locationQueue
+	publisher  Publisher
+	// topicRegionMap holds the region each device is currently inside
+	topicRegionMap map[string]string
+	// lastLocations holds the last location posted for each device
+	lastLocations map[string]source.Location
+	// rateLimits holds the rate limiting state of each device
+	rateLimits map[string]*rateLimit
+	// pausedZones holds the privacy zone each device with a paused session is inside
+	pausedZones map[string]string
+	// lastForwarded holds the last coordinates of each device which passed all checks, used in notifications
+	lastForwarded map[string]geo.Point
+	// failedSessions holds the devices for which creating a session failed since the last success
+	failedSessions map[string]bool
+	// silences holds the timers noticing when devices stop sending locations
+	silences map[string]*silence
 }
 
-// New creates a new instance of the mapper orchestrating mqtt and Hauk
-func New(config Config, haukClient hauk.Client, notifier notification.Notifier) Mapper {
-	return Mapper{topicSessionMap: make(map[string]hauk.Session), haukClient: haukClient, config: config, notifier: notifier}
+// New creates a new instance of the mapper orchestrating sources and Hauk.
+// The publisher is optional, without it sessions are not published to the devices.
+func New(config Config, haukClient hauk.Client, notifier notification.Notifier, sessions store.Store, publisher Publisher) *Mapper {
+	mapper := &Mapper{
+		sessions:       sessions,
+		haukClient:     haukClient,
+		config:         config,
+		notifier:       notifier,
+		publisher:      publisher,
+		topicRegionMap: make(map[string]string),
+		lastLocations:  make(map[string]source.Location),
+		rateLimits:     make(map[string]*rateLimit),
+		pausedZones:    make(map[string]string),
+		lastForwarded:  make(map[string]geo.Point),
+		failedSessions: make(map[string]bool),
+		silences:       make(map[string]*silence),
+	}
+	if config.Queue.Enabled {
+		queue, err := newLocationQueue(config.Queue)
+		if err != nil {
+			log.Printf("Could not restore queued locations: %v", err)
+		}
+		mapper.queue = queue
+	}
+	return mapper
 }
 
-// Run maps mqtt messages to hauk API calls
-func (t *Mapper) Run(messages <-chan mqtt.Message) {
+// Run maps events of the sources to hauk API calls
+func (t *Mapper) Run(events <-chan source.Event) {
+	if t.queue != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go t.retryQueued(stop)
+	}
 
-	for message := range messages {
-		locationParams, err := createLocationParamsFromMessage(message)
-		if err != nil {
-			log.Printf("Message invalid, skipping: %s\n", err.Error())
-			continue
+	for event := range events {
+		t.handleEvent(event)
+	}
+	t.flushAllRateLimited()
+}
+
+// Sessions returns the current session of each topic
+func (t *Mapper) Sessions() map[string]store.Entry {
+	return t.sessions.All()
+}
+
+// ShareURL returns the URL of the current session of the topic which can be shared with viewers
+func (t *Mapper) ShareURL(topic string) (string, bool) {
+	entry, sessionExists := t.sessions.Get(topic)
+	if !sessionExists {
+		return "", false
+	}
+	return getShareURL(entry.Session, t.config.device(topic).e2ePassword), true
+}
+
+// StartSession creates a new session for the topic, replacing the current one
+func (t *Mapper) StartSession(topic string) (hauk.Session, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	log.Printf("Session for %s requested, creating session\n", topic)
+	return t.createNewSessionForTopic(topic)
+}
+
+// StopSession stops the current session of the topic
+func (t *Mapper) StopSession(topic string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entry, sessionExists := t.sessions.Get(topic)
+	if !sessionExists {
+		return fmt.Errorf("Session for topic %s does not exist", topic)
+	}
+	log.Printf("Stopping session for %s: %v", topic, entry.Session)
+	if err := t.haukClient.StopSession(entry.Session.SID); err != nil {
+		return err
+	}
+	t.unpublishSession(topic)
+	if err := t.sessions.Delete(topic); err != nil {
+		return err
+	}
+	t.notify(notification.EventSessionStopped, topic, entry)
+	return nil
+}
+
+// ExtendSession replaces the current session of the topic with one lasting the given duration longer.
+// Hauk cannot extend sessions, so viewers get a new link.
+func (t *Mapper) ExtendSession(topic string, duration time.Duration) (store.Entry, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entry, sessionExists := t.sessions.Get(topic)
+	if !sessionExists {
+		return store.Entry{}, fmt.Errorf("Session for topic %s does not exist", topic)
+	}
+	remaining := time.Until(entry.Expires)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	log.Printf("Extending session for %s by %v: %v", topic, duration, entry.Session)
+	// The current session is only stopped once the new one exists, so a failure keeps it
+	if _, err := t.createSessionWithDuration(topic, remaining+duration, true); err != nil {
+		return store.Entry{}, err
+	}
+	entry, _ = t.sessions.Get(topic)
+	return entry, nil
+}
+
+func (t *Mapper) handleEvent(event source.Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	switch event.Type {
+	case source.TypeEnter, source.TypeLeave:
+		t.handleTransition(event)
+		return
+	case source.TypeLocation:
+		t.watchSilence(event.Topic)
+	default:
+		log.Printf("Event type %s invalid, skipping\n", event.Type)
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonInvalid).Inc()
+		return
+	}
+
+	timeCheck := t.checkTime(event)
+	if timeCheck == timeSkip {
+		return
+	}
+	event, passed := t.filterLocation(event)
+	if !passed {
+		return
+	}
+	// Stale locations must not pause or resume sessions
+	event, passed = t.applyPrivacy(event, timeCheck != timeBacklog)
+	if !passed {
+		return
+	}
+	if timeCheck == timeBacklog {
+		t.deliverBacklog(event)
+		return
+	}
+
+	if t.isInsideRegion(event) {
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonGeofence).Inc()
+		return
+	}
+
+	if t.limitRate(event) {
+		return
+	}
+	t.forward(event)
+}
+
+// forward delivers the location to Hauk or queues it if Hauk is unreachable
+func (t *Mapper) forward(event source.Event) {
+	t.lastForwarded[event.Topic] = geo.Point{Latitude: event.Location.Latitude, Longitude: event.Location.Longitude}
+
+	// Locations are delivered in order, so queue behind undelivered ones
+	if t.queue != nil && t.queue.contains(event.Topic) {
+		t.enqueue(event)
+		t.flushQueue(event.Topic)
+		return
+	}
+
+	if retryEvent, retry := t.deliver(event); retry {
+		t.enqueue(retryEvent)
+	}
+}
+
+// deliver posts the location to the session of the topic.
+// If Hauk is unreachable and the queue is enabled, it returns the event to retry later.
+func (t *Mapper) deliver(event source.Event) (source.Event, bool) {
+	session, err := t.getOrCreateSession(event)
+	if err != nil {
+		if t.isRetryable(err) {
+			log.Printf("Hauk unreachable, queueing location for %s: %v\n", event.Topic, err)
+			return event, true
 		}
+		log.Printf("%v\n", err.Error())
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonNoSession).Inc()
+		return event, false
+	}
 
-		sid, err := t.getOrCreateSID(message)
-		if err != nil {
-			log.Printf("%v\n", err.Error())
-			continue
+	locationParams := createLocationParams(event.Location)
+	err = t.haukClient.PostLocation(session, locationParams)
+	err = t.handleExpiredSession(err, event.Topic, locationParams)
+	if err != nil {
+		if t.isRetryable(err) {
+			log.Printf("Hauk unreachable, queueing location for %s: %v\n", event.Topic, err)
+			// The session already exists, so a retry must not start yet another one
+			return withoutTrigger(event), true
 		}
+		log.Printf("Could not post location, skipping: %s", err.Error())
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonPostFailed).Inc()
+		return event, false
+	}
+	metrics.LocationsPosted.WithLabelValues(event.Topic).Inc()
+	t.setLastLocation(event)
+	return event, false
+}
 
-		err = t.haukClient.PostLocation(sid, locationParams)
-		err = t.handleExpiredSession(err, message, locationParams)
-		if err != nil {
-			log.Printf("Could not handle expired session, skipping location: %s", err.Error())
+func (t *Mapper) isRetryable(err error) bool {
+	var unreachableError *hauk.UnreachableError
+	return t.queue != nil && errors.As(err, &unreachableError)
+}
+
+func (t *Mapper) enqueue(event source.Event) {
+	metrics.LocationsQueued.WithLabelValues(event.Topic).Inc()
+	if !t.queue.push(event) {
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonQueueFull).Inc()
+	}
+	if err := t.queue.save(); err != nil {
+		log.Printf("Could not persist location queue: %v", err)
+	}
+}
+
+// flushQueue delivers the queued locations of the topic in order.
+// It returns false if Hauk is still unreachable.
+func (t *Mapper) flushQueue(topic string) bool {
+	defer func() {
+		if err := t.queue.save(); err != nil {
+			log.Printf("Could not persist location queue: %v", err)
+		}
+	}()
+	for {
+		event, queued := t.queue.peek(topic)
+		if !queued {
+			return true
+		}
+		retryEvent, retry := t.deliver(event)
+		if retry {
+			t.queue.replaceHead(retryEvent)
+			return false
+		}
+		t.queue.pop(topic)
+	}
+}
+
+// retryQueued periodically retries delivering queued locations with exponential backoff until stop is closed
+func (t *Mapper) retryQueued(stop <-chan struct{}) {
+	backoff := t.config.Queue.RetryInitial
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		if t.flushQueues() {
+			backoff = t.config.Queue.RetryInitial
+		} else {
+			backoff *= 2
+			if backoff > t.config.Queue.RetryMax {
+				backoff = t.config.Queue.RetryMax
+			}
+			log.Printf("Hauk still unreachable, retrying queued locations in %v\n", backoff)
+		}
+		timer.Reset(backoff)
+	}
+}
+
+// flushQueues delivers the queued locations of all topics.
+// A topic which cannot be delivered does not hold back the others.
+func (t *Mapper) flushQueues() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	flushed := true
+	for _, topic := range t.queue.topics() {
+		if !t.flushQueue(topic) {
+			flushed = false
 		}
 	}
+	return flushed
+}
+
+// withoutTrigger returns a copy of the event which does not trigger a new session
+func withoutTrigger(event source.Event) source.Event {
+	event.Manual = false
+	return event
 }
 
-func (t *Mapper) getOrCreateSID(message mqtt.Message) (string, error) {
-	if t.config.SessionStartManual && message.Body[mqtt.ParamTrigger] == mqtt.TriggerManual {
-		return t.createNewSIDForTopic(message.Topic)
+func (t *Mapper) getOrCreateSession(event source.Event) (hauk.Session, error) {
+	if t.config.device(event.Topic).sessionStartManual && event.Manual {
+		return t.createNewSessionForTopic(event.Topic)
 	}
-	return t.getCurrentSIDForTopic(message.Topic)
+	return t.getCurrentSessionForTopic(event.Topic)
 }
 
-func (t *Mapper) getCurrentSIDForTopic(topic string) (string, error) {
-	session, sessionExists := t.topicSessionMap[topic]
+func (t *Mapper) getCurrentSessionForTopic(topic string) (hauk.Session, error) {
+	entry, sessionExists := t.sessions.Get(topic)
 	if !sessionExists {
-		if t.config.SessionStartAuto {
+		if t.config.device(topic).sessionStartAuto {
 			log.Printf("New topic %s, creating session\n", topic)
-			return t.createNewSIDForTopic(topic)
+			return t.createNewSessionForTopic(topic)
 		}
-		return "", fmt.Errorf("Session for topic %s does not exist and autostart is disabled", topic)
+		return hauk.Session{}, fmt.Errorf("Session for topic %s does not exist and autostart is disabled", topic)
 	}
-	return session.SID, nil
+	return entry.Session, nil
 }
 
-func (t *Mapper) createNewSIDForTopic(topic string) (string, error) {
+func (t *Mapper) createNewSessionForTopic(topic string) (hauk.Session, error) {
+	device := t.config.device(topic)
+	return t.createSessionWithDuration(topic, device.sessionDuration, device.sessionStopAuto)
+}
+
+// createSessionWithDuration creates a new session for the topic replacing the current one.
+// If stopCurrent is true, the current session is stopped after the new one has been created.
+func (t *Mapper) createSessionWithDuration(topic string, duration time.Duration, stopCurrent bool) (hauk.Session, error) {
+	device := t.config.device(topic)
+
+	// Create new Session
+	newSession, err := t.haukClient.CreateSession(hauk.SessionOptions{
+		E2EPassword: device.e2ePassword,
+		Duration:    duration,
+		Interval:    device.sessionInterval,
+	})
+	if err != nil {
+		// Failures are retried with each location, notify only about the first one
+		if !t.failedSessions[topic] {
+			t.failedSessions[topic] = true
+			t.notifier.Notify(notification.Event{Type: notification.EventSessionFailed, Device: device.notification, Err: err})
+		}
+		return newSession, err
+	}
+	metrics.SessionsCreated.WithLabelValues(topic).Inc()
 
 	// Stop current session
-	if t.config.SessionStopAuto {
-		if currentSession, sessionExists := t.topicSessionMap[topic]; sessionExists {
-			log.Printf("Stopping current session for %s: %v", topic, currentSession)
-			err := t.haukClient.StopSession(currentSession.SID)
+	if stopCurrent {
+		if currentEntry, sessionExists := t.sessions.Get(topic); sessionExists {
+			log.Printf("Stopping current session for %s: %v", topic, currentEntry.Session)
+			err := t.haukClient.StopSession(currentEntry.Session.SID)
 			if err != nil {
-				log.Printf("Error while stopping current session %+v: %v", currentSession, err)
+				log.Printf("Error while stopping current session %+v: %v", currentEntry.Session, err)
 			}
 		}
 	}
 
-	// Create new Session
-	newSession, err := t.haukClient.CreateSession()
-	if err != nil {
-		return "n/a", err
+	now := time.Now()
+	entry := store.Entry{Session: newSession, Created: now, Expires: now.Add(duration)}
+	if err = t.sessions.Put(topic, entry); err != nil {
+		log.Printf("Could not store session for %s: %v", topic, err)
 	}
-	t.topicSessionMap[topic] = newSession
 
-	// send email notification
-	t.notifier.NotifyNewSession(topic, newSession.URL)
+	delete(t.failedSessions, topic)
+	t.notify(notification.EventNewSession, topic, entry)
+	shareURL := getShareURL(newSession, device.e2ePassword)
+	t.publishSession(topic, entry)
 
 	// Print QR code on terminal
-	log.Printf("New session for %s: %v", topic, newSession)
-	qrterminal.GenerateHalfBlock(newSession.URL, qrterminal.L, os.Stdout)
+	log.Printf("New session for %s: %s", topic, newSession.URL)
+	qrterminal.GenerateHalfBlock(shareURL, qrterminal.L, os.Stdout)
+
+	return newSession, nil
+}
 
-	return newSession.SID, nil
+// notify sends a notification about the session of the topic
+func (t *Mapper) notify(eventType string, topic string, entry store.Entry) {
+	device := t.config.device(topic)
+	shareURL := getShareURL(entry.Session, device.e2ePassword)
+	t.notifier.Notify(notification.Event{
+		Type:    eventType,
+		Device:  device.notification,
+		Session: t.notificationSession(topic, entry, shareURL),
+	})
 }
 
-func (t *Mapper) handleExpiredSession(err error, message mqtt.Message, locationParams url.Values) error {
+// notificationSession describes the session in notifications
+func (t *Mapper) notificationSession(topic string, entry store.Entry, shareURL string) notification.Session {
+	session := notification.Session{URL: shareURL, Created: entry.Created, Expires: entry.Expires}
+	if point, forwarded := t.lastForwarded[topic]; forwarded {
+		session.LastLocation = &point
+	}
+	return session
+}
+
+// getShareURL returns the session URL, for end-to-end encrypted sessions including the password as fragment.
+// The fragment is never sent to the Hauk server, but allows viewers to decrypt the locations.
+func getShareURL(session hauk.Session, e2ePassword string) string {
+	if !session.IsE2E() || e2ePassword == "" {
+		return session.URL
+	}
+	return session.URL + "#" + url.PathEscape(e2ePassword)
+}
+
+func (t *Mapper) handleExpiredSession(err error, topic string, locationParams url.Values) error {
 	if err != nil {
 		switch err.(type) {
 		case *hauk.SessionExpiredError:
+			metrics.SessionsExpired.WithLabelValues(topic).Inc()
+			if entry, sessionExists := t.sessions.Get(topic); sessionExists {
+				t.notify(notification.EventSessionExpired, topic, entry)
+			}
 			// Remove expired session
-			delete(t.topicSessionMap, message.Topic)
-			if t.config.SessionStartAuto {
+			if err = t.sessions.Delete(topic); err != nil {
+				log.Printf("Could not remove expired session for %s: %v", topic, err)
+			}
+			t.unpublishSession(topic)
+			if t.config.device(topic).sessionStartAuto {
 				// Create new session
-				log.Printf("Session for %s expired, creating new one\n", message.Topic)
-				var newSID string
-				if newSID, err = t.createNewSIDForTopic(message.Topic); err != nil {
+				log.Printf("Session for %s expired, creating new one\n", topic)
+				var newSession hauk.Session
+				if newSession, err = t.createNewSessionForTopic(topic); err != nil {
 					log.Printf("%v", err.Error())
 					return err
 				}
 				// re-send location
 				log.Println("Re-posting location to new session")
-				return t.haukClient.PostLocation(newSID, locationParams)
+				return t.haukClient.PostLocation(newSession, locationParams)
 			}
 			return nil
 		default:
@@ -146,44 +461,41 @@ func (t *Mapper) handleExpiredSession(err error, message mqtt.Message, locationP
 	return nil
 }
 
-func createLocationParamsFromMessage(msg mqtt.Message) (url.Values, error) {
-	body := msg.Body
+// createLocationParams converts the location into the parameters of Hauk's post API
+func createLocationParams(location source.Location) url.Values {
 	haukValues := url.Values{}
-	if body[mqtt.ParamType] == "location" {
-		for mqttKey, mqttValue := range body {
-			setHaukValue(&haukValues, mqttKey, mqttValue)
-
-		}
-		return haukValues, nil
+	haukValues.Set(hauk.ParamLatitude, formatFloat(location.Latitude))
+	haukValues.Set(hauk.ParamLongitude, formatFloat(location.Longitude))
+	if location.Altitude != nil {
+		haukValues.Set(hauk.ParamAltitude, formatFloat(*location.Altitude))
 	}
-	return haukValues, fmt.Errorf("Type is not location")
-
-}
-
-func setHaukValue(haukValues *url.Values, key string, value interface{}) {
-	valueMapping, hasMapping := mqttToHaukKeyMap[key]
-	if hasMapping {
-		var convertedValue string
-		if valueMapping.mappingFunction != nil {
-			convertedValue = valueMapping.mappingFunction(value)
+	if location.Accuracy != nil {
+		haukValues.Set(hauk.ParamAccuracy, formatFloat(*location.Accuracy))
+	}
+	if location.Speed != nil {
+		haukValues.Set(hauk.ParamVelocity, fmt.Sprintf("%f", *location.Speed))
+	}
+	if !location.Time.IsZero() {
+		// Hauk Android client also sends float, but formatted differently.
+		// Before converting to int the frontend sometimes did not update.
+		haukValues.Set(hauk.ParamTime, fmt.Sprintf("%d", location.Time.Unix()))
+	}
+	if location.AccuracyMode != nil {
+		haukValues.Set(hauk.ParamProvider, strconv.Itoa(*location.AccuracyMode))
+	}
+	if location.Battery != nil {
+		haukValues.Set(hauk.ParamBattery, formatFloat(*location.Battery))
+	}
+	if location.Charging != nil {
+		if *location.Charging {
+			haukValues.Set(hauk.ParamCharging, "1")
 		} else {
-			convertedValue = fmt.Sprintf("%v", value)
+			haukValues.Set(hauk.ParamCharging, "0")
 		}
-		haukValues.Add(valueMapping.haukKey, convertedValue)
 	}
+	return haukValues
 }
 
-func convertToFloat(value interface{}) float64 {
-	var floatValue float64 = 0
-	switch value.(type) {
-	case float64:
-		floatValue = value.(float64)
-	case int:
-		floatValue = float64(value.(int))
-	case int32:
-		floatValue = float64(value.(int32))
-	case int64:
-		floatValue = float64(value.(int64))
-	}
-	return floatValue
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
 }
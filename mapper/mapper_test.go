@@ -3,24 +3,30 @@ package mapper
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
 	"github.com/tuffnerdstuff/hauk-snitch/hauk"
-	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
 )
 
 type MockHaukClient struct {
 	mock.Mock
 }
 
-func (t *MockHaukClient) CreateSession() (hauk.Session, error) {
-	args := t.Called()
+func (t *MockHaukClient) CreateSession(options hauk.SessionOptions) (hauk.Session, error) {
+	args := t.Called(options)
 	return args.Get(0).(hauk.Session), args.Error(1)
 }
 
-func (t *MockHaukClient) PostLocation(sid string, params url.Values) error {
-	args := t.Called(sid, params)
+func (t *MockHaukClient) PostLocation(session hauk.Session, params url.Values) error {
+	args := t.Called(session.SID, params)
 	return args.Error(0)
 }
 
@@ -33,17 +39,24 @@ type MockNotifier struct {
 	mock.Mock
 }
 
-func (t *MockNotifier) NotifyNewSession(topic string, URL string) {
-	t.Called(topic, URL)
+func (t *MockNotifier) Notify(event notification.Event) {
+	t.Called(event.Type, event.Device, event.Session.URL)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (t *MockPublisher) Publish(topic string, payload []byte, retained bool) error {
+	args := t.Called(topic, string(payload), retained)
+	return args.Error(0)
 }
 
 func TestMapMessageToLocation_TypeNotLocation_Error(t *testing.T) {
 	// given: type is not location
-	body := make(map[string]interface{})
-	body["_type"] = "somethingelse"
-	mqttLocations := make(chan mqtt.Message, 1)
-	mqttLocations <- mqtt.Message{Topic: "whatevs", Body: body}
-	close(mqttLocations)
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "whatevs", Type: "somethingelse"}
+	close(events)
 
 	// given: Mock hauk client
 	haukClient := new(MockHaukClient)
@@ -56,8 +69,8 @@ func TestMapMessageToLocation_TypeNotLocation_Error(t *testing.T) {
 		SessionStartAuto:   true,
 		SessionStartManual: true,
 		SessionStopAuto:    true,
-	}, haukClient, notifier)
-	mapper.Run(mqttLocations)
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
 
 	// then: assert mock calls
 	haukClient.AssertExpectations(t)
@@ -97,13 +110,12 @@ func TestRun_NoSession(t *testing.T) {
 func testSessionHandling(t *testing.T, startSessionAuto bool, startSessionManual bool, stopSessionAuto bool) {
 
 	// given: valid locations
-	locationAuto1 := createValidLocationBody()
-	locationAuto1["tst"] = float64(1)
-	locationManual := createValidLocationBody()
-	locationManual["t"] = "u"
-	locationManual["tst"] = float64(2)
-	locationAuto2 := createValidLocationBody()
-	locationAuto2["tst"] = float64(3)
+	locationAuto1 := createValidLocation()
+	locationAuto1.Time = time.Unix(1, 0)
+	locationManual := createValidLocation()
+	locationManual.Time = time.Unix(2, 0)
+	locationAuto2 := createValidLocation()
+	locationAuto2.Time = time.Unix(3, 0)
 
 	// given: Mock hauk client
 	haukClient := new(MockHaukClient)
@@ -112,17 +124,19 @@ func testSessionHandling(t *testing.T, startSessionAuto bool, startSessionManual
 	notifier := new(MockNotifier)
 
 	currentSID := "n/a"
+	sessionURLs := map[string]string{"secondSession": "secondURL", "thirdSession": "thirdURL"}
 	// auto push locationAuto1
 	if startSessionAuto {
 		// --> CreateSession "firstSession"
-		haukClient.On("CreateSession").Return(hauk.Session{SID: "firstSession", URL: "firstURL"}, nil).Once()
-		notifier.On("NotifyNewSession", "whatevs", "firstURL").Once()
+		haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "firstSession", URL: "firstURL"}, nil).Once()
+		notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "firstURL").Once()
 		// --> PostLocation to "firstSession"
 		haukClient.On("PostLocation", "firstSession", getExpectedLocationValues(locationAuto1)).Return(&hauk.SessionExpiredError{}).Once()
 		// handle expired session
+		notifier.On("Notify", notification.EventSessionExpired, notification.Device{Topic: "whatevs"}, "firstURL").Once()
 		// --> CreateSession "secondSession"
-		haukClient.On("CreateSession").Return(hauk.Session{SID: "secondSession", URL: "secondURL"}, nil).Once()
-		notifier.On("NotifyNewSession", "whatevs", "secondURL").Once()
+		haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "secondSession", URL: "secondURL"}, nil).Once()
+		notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "secondURL").Once()
 		// --> PostLocation to "secondSession" (re-send)
 		haukClient.On("PostLocation", "secondSession", getExpectedLocationValues(locationAuto1)).Return(nil).Once()
 		currentSID = "secondSession"
@@ -134,8 +148,8 @@ func testSessionHandling(t *testing.T, startSessionAuto bool, startSessionManual
 			haukClient.On("StopSession", currentSID).Return(nil).Once()
 		}
 		// --> CreateSession "thirdSession"
-		haukClient.On("CreateSession").Return(hauk.Session{SID: "thirdSession", URL: "thirdURL"}, nil).Once()
-		notifier.On("NotifyNewSession", "whatevs", "thirdURL").Once()
+		haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "thirdSession", URL: "thirdURL"}, nil).Once()
+		notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "thirdURL").Once()
 		// --> PostLocation to "thirdSession"
 		haukClient.On("PostLocation", "thirdSession", getExpectedLocationValues(locationManual)).Return(nil).Once()
 		currentSID = "thirdSession"
@@ -149,36 +163,37 @@ func testSessionHandling(t *testing.T, startSessionAuto bool, startSessionManual
 		// --> PostLocation to "secondSession" / "thirdSession"
 		haukClient.On("PostLocation", currentSID, getExpectedLocationValues(locationAuto2)).Return(&hauk.SessionExpiredError{}).Once()
 		// handle expired session
+		notifier.On("Notify", notification.EventSessionExpired, notification.Device{Topic: "whatevs"}, sessionURLs[currentSID]).Once()
 		if startSessionAuto {
 			// --> CreateSession "lastSession"
-			haukClient.On("CreateSession").Return(hauk.Session{SID: "lastSession", URL: "lastURL"}, nil).Once()
-			notifier.On("NotifyNewSession", "whatevs", "lastURL").Once()
+			haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "lastSession", URL: "lastURL"}, nil).Once()
+			notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "lastURL").Once()
 			// --> PostLocation to "secondSession" (re-send)
 			haukClient.On("PostLocation", "lastSession", getExpectedLocationValues(locationAuto2)).Return(nil).Once()
 		}
 	}
 
-	// given: mqtt Location channel
-	mqttLocations := make(chan mqtt.Message, 3)
+	// given: event channel
+	events := make(chan source.Event, 3)
 
 	// given: First location update
-	mqttLocations <- mqtt.Message{Topic: "whatevs", Body: locationAuto1}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: locationAuto1}
 
 	// given: Second location update
-	mqttLocations <- mqtt.Message{Topic: "whatevs", Body: locationManual}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: locationManual, Manual: true}
 
 	// given: Third location update
-	mqttLocations <- mqtt.Message{Topic: "whatevs", Body: locationAuto2}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: locationAuto2}
 
-	close(mqttLocations)
+	close(events)
 
 	// when
 	mapper := New(Config{
 		SessionStartAuto:   startSessionAuto,
 		SessionStartManual: startSessionManual,
 		SessionStopAuto:    stopSessionAuto,
-	}, haukClient, notifier)
-	mapper.Run(mqttLocations)
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
 
 	// then: assert mock calls
 	haukClient.AssertExpectations(t)
@@ -186,33 +201,378 @@ func testSessionHandling(t *testing.T, startSessionAuto bool, startSessionManual
 
 }
 
-func getExpectedLocationValues(location map[string]interface{}) url.Values {
+func getExpectedLocationValues(location source.Location) url.Values {
 	return url.Values{
-		"lat":  {fmt.Sprintf("%v", location["lat"])},
-		"lon":  {fmt.Sprintf("%v", location["lon"])},
-		"acc":  {fmt.Sprintf("%v", location["acc"])},
-		"alt":  {fmt.Sprintf("%v", location["alt"])},
-		"spd":  {fmt.Sprintf("%f", float64(location["vel"].(int))/3.6)},
-		"time": {fmt.Sprintf("%d", int64(location["tst"].(float64)))},
+		"lat":  {fmt.Sprintf("%v", location.Latitude)},
+		"lon":  {fmt.Sprintf("%v", location.Longitude)},
+		"acc":  {fmt.Sprintf("%v", *location.Accuracy)},
+		"alt":  {fmt.Sprintf("%v", *location.Altitude)},
+		"spd":  {fmt.Sprintf("%f", *location.Speed)},
+		"time": {fmt.Sprintf("%d", location.Time.Unix())},
+	}
+}
+
+func createValidLocation() source.Location {
+	altitude := 362.0
+	accuracy := 5.0
+	speed := 42 / 3.6
+	return source.Location{
+		Latitude:  47.5968792,
+		Longitude: 12.9540961,
+		Altitude:  &altitude,
+		Accuracy:  &accuracy,
+		Speed:     &speed,
+		Time:      time.Unix(1618243873, 0),
+	}
+}
+
+func TestRun_E2ESession(t *testing.T) {
+	// given: location of a topic with an e2e password
+	location := createValidLocation()
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "owntracks/user/phone", Type: source.TypeLocation, Location: location}
+	close(events)
+
+	// given: Mock hauk client
+	haukClient := new(MockHaukClient)
+	e2eSession := hauk.Session{SID: "e2eSession", URL: "e2eURL", E2EKey: []byte("key")}
+	haukClient.On("CreateSession", hauk.SessionOptions{E2EPassword: "my secret"}).Return(e2eSession, nil).Once()
+	haukClient.On("PostLocation", "e2eSession", getExpectedLocationValues(location)).Return(nil).Once()
+
+	// given: notifier expects link including password fragment
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "owntracks/user/phone"}, "e2eURL#my%20secret").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		E2EPassword:      "global",
+		E2EPasswords:     []E2EPassword{{Topic: "owntracks/user/+", Password: "my secret"}},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then: assert mock calls
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_QueueLocationsWhileHaukUnreachable(t *testing.T) {
+	// given: two locations
+	location1 := createValidLocation()
+	location1.Time = time.Unix(1, 0)
+	location2 := createValidLocation()
+	location2.Time = time.Unix(2, 0)
+	events := make(chan source.Event, 2)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location1}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location2}
+	close(events)
+
+	// given: Hauk is unreachable for the first location, then recovers
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location1)).Return(&hauk.UnreachableError{Err: fmt.Errorf("timeout")}).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location1)).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location2)).Return(nil).Once()
+
+	// given: notifier
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Queue:            QueueConfig{Enabled: true, Mode: QueueModeAll, Size: 10, RetryInitial: time.Hour, RetryMax: time.Hour},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then: both locations are delivered, queue is empty
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+	assert.False(t, mapper.queue.contains("whatevs"))
+}
+
+func TestRun_QueueDropsRejectedLocation(t *testing.T) {
+	// given: two locations
+	location1 := createValidLocation()
+	location1.Time = time.Unix(1, 0)
+	location2 := createValidLocation()
+	location2.Time = time.Unix(2, 0)
+	events := make(chan source.Event, 2)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location1}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location2}
+	close(events)
+
+	// given: Hauk rejects the first location
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location1)).Return(fmt.Errorf("Bad request")).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location2)).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Queue:            QueueConfig{Enabled: true, Mode: QueueModeAll, Size: 10, RetryInitial: time.Hour, RetryMax: time.Hour},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then: rejected location is dropped instead of blocking the queue
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+	assert.False(t, mapper.queue.contains("whatevs"))
+}
+
+func TestFlushQueues_ContinuesAfterFailingTopic(t *testing.T) {
+	// given: queued locations of two topics, Hauk is still unreachable for the first one
+	location := createValidLocation()
+	sessions := store.NewMemory()
+	sessions.Put("first", store.Entry{Session: hauk.Session{SID: "first"}})
+	sessions.Put("second", store.Entry{Session: hauk.Session{SID: "second"}})
+	haukClient := new(MockHaukClient)
+	haukClient.On("PostLocation", "first", getExpectedLocationValues(location)).Return(&hauk.UnreachableError{Err: fmt.Errorf("timeout")}).Once()
+	haukClient.On("PostLocation", "second", getExpectedLocationValues(location)).Return(nil).Once()
+	mapper := New(Config{
+		Queue: QueueConfig{Enabled: true, Mode: QueueModeAll, Size: 10},
+	}, haukClient, new(MockNotifier), sessions, nil)
+	mapper.queue.push(source.Event{Topic: "first", Type: source.TypeLocation, Location: location})
+	mapper.queue.push(source.Event{Topic: "second", Type: source.TypeLocation, Location: location})
+
+	// when
+	flushed := mapper.flushQueues()
+
+	// then: second topic is delivered anyway
+	assert.False(t, flushed)
+	haukClient.AssertExpectations(t)
+	assert.True(t, mapper.queue.contains("first"))
+	assert.False(t, mapper.queue.contains("second"))
+}
+
+func TestRun_QueueDisabledDropsLocation(t *testing.T) {
+	// given
+	location := createValidLocation()
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	close(events)
+
+	// given: Hauk is unreachable
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{}, &hauk.UnreachableError{Err: fmt.Errorf("timeout")}).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventSessionFailed, notification.Device{Topic: "whatevs"}, "").Once()
+
+	// when
+	mapper := New(Config{SessionStartAuto: true}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+	assert.Nil(t, mapper.queue)
+}
+
+func TestRun_SessionFailedNotifiedOnce(t *testing.T) {
+	// given: two locations
+	location1 := createValidLocation()
+	location1.Time = time.Unix(1, 0)
+	location2 := createValidLocation()
+	location2.Time = time.Unix(2, 0)
+	events := make(chan source.Event, 2)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location1}
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location2}
+	close(events)
+
+	// given: Hauk rejects both sessions, failure is only notified once
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{}, fmt.Errorf("Forbidden")).Twice()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventSessionFailed, notification.Device{Topic: "whatevs"}, "").Once()
+
+	// when
+	mapper := New(Config{SessionStartAuto: true}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestLocationQueue_Push(t *testing.T) {
+	// given
+	allQueue, _ := newLocationQueue(QueueConfig{Mode: QueueModeAll, Size: 2})
+	latestQueue, _ := newLocationQueue(QueueConfig{Mode: QueueModeLatest})
+
+	// when
+	for i := 1; i <= 3; i++ {
+		event := source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: source.Location{Time: time.Unix(int64(i), 0)}}
+		allQueue.push(event)
+		latestQueue.push(event)
 	}
+
+	// then: "all" keeps the newest locations up to the size, "latest" only the newest
+	assert.Len(t, allQueue.events["whatevs"], 2)
+	assert.Equal(t, time.Unix(2, 0), allQueue.events["whatevs"][0].Location.Time)
+	assert.Len(t, latestQueue.events["whatevs"], 1)
+	assert.Equal(t, time.Unix(3, 0), latestQueue.events["whatevs"][0].Location.Time)
+}
+
+func TestRun_PublishSession(t *testing.T) {
+	// given: a location creating a session, which is stopped via API afterwards
+	location := createValidLocation()
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "owntracks/user/phone", Type: source.TypeLocation, Location: location}
+	close(events)
+
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location)).Return(nil).Once()
+	haukClient.On("StopSession", "session").Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "owntracks/user/phone"}, "URL").Once()
+	notifier.On("Notify", notification.EventSessionStopped, notification.Device{Topic: "owntracks/user/phone"}, "URL").Once()
+
+	// given: session is published retained, link is sent to the app, retained session is removed on stop
+	publisher := new(MockPublisher)
+	publisher.On("Publish", "owntracks/user/phone/hauk", mock.MatchedBy(func(payload string) bool {
+		return strings.Contains(payload, `"url":"URL"`)
+	}), true).Return(nil).Once()
+	publisher.On("Publish", "owntracks/user/phone/cmd", `{"_type":"cmd","action":"action","content":"Sharing location via Hauk: URL","url":"URL"}`, false).Return(nil).Once()
+	publisher.On("Publish", "owntracks/user/phone/hauk", "", true).Return(nil).Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Publish:          PublishConfig{Session: true, Cmd: true},
+	}, haukClient, notifier, store.NewMemory(), publisher)
+	mapper.Run(events)
+	err := mapper.StopSession("owntracks/user/phone")
+
+	// then
+	assert.NoError(t, err)
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+	publisher.AssertExpectations(t)
 }
 
-func createValidLocationBody() map[string]interface{} {
-	body := make(map[string]interface{})
-	body["_type"] = "location"
-	body["acc"] = 5
-	body["alt"] = 362
-	body["batt"] = 76
-	body["bs"] = 1
-	body["conn"] = "w"
-	body["created_at"] = 1.61826091e+09
-	body["inregions"] = [...]string{"Home"}
-	body["lat"] = 47.5968792
-	body["lon"] = 12.9540961
-	body["t"] = "p"
-	body["tid"] = "dy"
-	body["tst"] = 1.618243873e+09
-	body["vac"] = 3
-	body["vel"] = 42
-	return body
+func TestRun_PublishSessionWithoutPassword(t *testing.T) {
+	// given: a location creating an end-to-end encrypted session
+	location := createValidLocation()
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "owntracks/user/phone", Type: source.TypeLocation, Location: location}
+	close(events)
+
+	e2eSession := hauk.Session{SID: "session", URL: "URL", E2EKey: []byte("key")}
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{E2EPassword: "my secret"}).Return(e2eSession, nil).Once()
+	haukClient.On("PostLocation", "session", mock.Anything).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "owntracks/user/phone"}, "URL#my%20secret").Once()
+
+	// given: published link does not contain the password
+	publisher := new(MockPublisher)
+	publisher.On("Publish", "owntracks/user/phone/hauk", mock.MatchedBy(func(payload string) bool {
+		return strings.Contains(payload, `"url":"URL"`)
+	}), true).Return(nil).Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		E2EPassword:      "my secret",
+		Publish:          PublishConfig{Session: true},
+	}, haukClient, notifier, store.NewMemory(), publisher)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestExtendSession(t *testing.T) {
+	// given: a session expiring in about 10 minutes
+	sessions := store.NewMemory()
+	now := time.Now()
+	sessions.Put("whatevs", store.Entry{Session: hauk.Session{SID: "session"}, Created: now, Expires: now.Add(10 * time.Minute)})
+
+	// given: session is replaced with one lasting an hour longer
+	haukClient := new(MockHaukClient)
+	haukClient.On("StopSession", "session").Return(nil).Once()
+	haukClient.On("CreateSession", mock.MatchedBy(func(options hauk.SessionOptions) bool {
+		return options.Duration > 69*time.Minute && options.Duration <= 70*time.Minute
+	})).Return(hauk.Session{SID: "extended", URL: "URL"}, nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{SessionStopAuto: true}, haukClient, notifier, sessions, nil)
+	entry, err := mapper.ExtendSession("whatevs", time.Hour)
+	_, missingErr := mapper.ExtendSession("other", time.Hour)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "extended", entry.Session.SID)
+	assert.Error(t, missingErr)
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestExtendSession_CreateFailed(t *testing.T) {
+	// given: a session
+	sessions := store.NewMemory()
+	now := time.Now()
+	sessions.Put("whatevs", store.Entry{Session: hauk.Session{SID: "session"}, Created: now, Expires: now.Add(10 * time.Minute)})
+
+	// given: Hauk cannot create the new session
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", mock.Anything).Return(hauk.Session{}, fmt.Errorf("Forbidden")).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventSessionFailed, notification.Device{Topic: "whatevs"}, "").Once()
+
+	// when
+	mapper := New(Config{SessionStopAuto: true}, haukClient, notifier, sessions, nil)
+	_, err := mapper.ExtendSession("whatevs", time.Hour)
+
+	// then: current session is kept
+	assert.Error(t, err)
+	entry, sessionExists := sessions.Get("whatevs")
+	assert.True(t, sessionExists)
+	assert.Equal(t, "session", entry.Session.SID)
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestCreateLocationParams_BatteryAndAccuracyMode(t *testing.T) {
+	// given
+	battery := 76.0
+	charging := true
+	accuracyMode := source.AccuracyModeCoarse
+	location := source.Location{Latitude: 47.5, Longitude: 12.9, Battery: &battery, Charging: &charging, AccuracyMode: &accuracyMode}
+
+	// when
+	params := createLocationParams(location)
+
+	// then: unknown values are omitted
+	assert.Equal(t, url.Values{
+		"lat": {"47.5"},
+		"lon": {"12.9"},
+		"prv": {"1"},
+		"bat": {"76"},
+		"chg": {"1"},
+	}, params)
+}
+
+func TestNotificationSession(t *testing.T) {
+	// given: a device with a forwarded location and one without
+	mapper := New(Config{}, nil, nil, store.NewMemory(), nil)
+	mapper.lastForwarded["whatevs"] = geo.Point{Latitude: 47.5968792, Longitude: 12.9540961}
+	created := time.Unix(1618243873, 0)
+	entry := store.Entry{Created: created, Expires: created.Add(time.Hour)}
+
+	// when
+	session := mapper.notificationSession("whatevs", entry, "URL")
+	unknown := mapper.notificationSession("unknown", entry, "URL")
+
+	// then
+	assert.Equal(t, notification.Session{URL: "URL", Created: created, Expires: created.Add(time.Hour), LastLocation: &geo.Point{Latitude: 47.5968792, Longitude: 12.9540961}}, session)
+	assert.Nil(t, unknown.LastLocation)
 }
@@ -0,0 +1,86 @@
+package mapper
+
+import (
+	"log"
+
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// PrivacyZone is a circular or polygonal area in which the location of a device is masked
+type PrivacyZone struct {
+	Name string
+	// Topic pattern of the devices the zone applies to, empty for all
+	Topic string
+	// Area is a geo.Circle or geo.Polygon
+	Area geo.Area
+	// Mode is PrivacyModeSuppress, PrivacyModeEdge, PrivacyModeCentroid or PrivacyModeGrid
+	Mode string
+	// GridSize is the size of the grid cells in meters for PrivacyModeGrid
+	GridSize float64
+	// PauseSession stops the session while the device is inside and starts a new one when it leaves
+	PauseSession bool
+}
+
+func (t *Config) privacyZoneContaining(topic string, point geo.Point) (PrivacyZone, bool) {
+	for _, zone := range t.PrivacyZones {
+		if zone.Topic != "" && !mqtt.MatchTopic(zone.Topic, topic) {
+			continue
+		}
+		if zone.Area.Contains(point) {
+			return zone, true
+		}
+	}
+	return PrivacyZone{}, false
+}
+
+// applyPrivacy masks the location if it lies within a privacy zone.
+// It returns false if the location must not be posted at all.
+// If trigger is false, the session is neither paused nor resumed.
+func (t *Mapper) applyPrivacy(event source.Event, trigger bool) (source.Event, bool) {
+	point := geo.Point{Latitude: event.Location.Latitude, Longitude: event.Location.Longitude}
+	zone, inside := t.config.privacyZoneContaining(event.Topic, point)
+	pausedZone, paused := t.pausedZones[event.Topic]
+
+	if trigger && paused && (!inside || zone.Name != pausedZone) {
+		delete(t.pausedZones, event.Topic)
+		if t.config.device(event.Topic).sessionStartAuto {
+			log.Printf("%s left privacy zone %s, creating session", event.Topic, pausedZone)
+			if _, err := t.createNewSessionForTopic(event.Topic); err != nil {
+				log.Printf("%v\n", err.Error())
+			}
+		}
+	}
+	if !inside {
+		return event, true
+	}
+
+	if zone.PauseSession {
+		// Only devices which were sharing are resumed when leaving
+		if trigger && t.pausedZones[event.Topic] != zone.Name && t.pauseSession(event.Topic) {
+			log.Printf("%s entered privacy zone %s, session stopped", event.Topic, zone.Name)
+			t.pausedZones[event.Topic] = zone.Name
+		}
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonPrivacy).Inc()
+		return event, false
+	}
+
+	var masked geo.Point
+	switch zone.Mode {
+	case PrivacyModeEdge:
+		masked = zone.Area.Edge(point)
+	case PrivacyModeCentroid:
+		masked = zone.Area.Centroid()
+	case PrivacyModeGrid:
+		masked = geo.Snap(point, zone.GridSize)
+	default:
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonPrivacy).Inc()
+		return event, false
+	}
+	event.Location.Latitude = masked.Latitude
+	event.Location.Longitude = masked.Longitude
+	metrics.LocationsMasked.WithLabelValues(event.Topic, zone.Mode).Inc()
+	return event, true
+}
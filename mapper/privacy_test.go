@@ -0,0 +1,126 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestRun_PrivacyZoneMasked(t *testing.T) {
+	// given: locations near home of a device with a centroid zone and a device with a suppressing zone
+	home := createValidLocation()
+	nearHome := createValidLocation()
+	nearHome.Latitude += 0.0003
+	events := make(chan source.Event, 2)
+	events <- source.Event{Topic: "owntracks/dude/phone", Type: source.TypeLocation, Location: nearHome}
+	events <- source.Event{Topic: "owntracks/kid/phone", Type: source.TypeLocation, Location: nearHome}
+	close(events)
+	center := geo.Point{Latitude: home.Latitude, Longitude: home.Longitude}
+
+	// given: only the location of dude is posted, moved to the center of home
+	masked := nearHome
+	masked.Latitude = home.Latitude
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(masked)).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "owntracks/dude/phone"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		PrivacyZones: []PrivacyZone{
+			{Name: "Home", Topic: "owntracks/dude/+", Area: geo.Circle{Center: center, Radius: 100}, Mode: PrivacyModeCentroid},
+			{Name: "Home", Topic: "owntracks/kid/+", Area: geo.Circle{Center: center, Radius: 100}, Mode: PrivacyModeSuppress},
+		},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_PrivacyZonePauseSession(t *testing.T) {
+	// given: location away, at work and away again
+	away := createValidLocation()
+	away.Time = time.Unix(1, 0)
+	work := createValidLocation()
+	work.Latitude = 47.7
+	work.Time = time.Unix(2, 0)
+	awayAgain := createValidLocation()
+	awayAgain.Time = time.Unix(3, 0)
+	events := make(chan source.Event, 3)
+	for _, location := range []source.Location{away, work, awayAgain} {
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	}
+	close(events)
+
+	// given: session is stopped at work and a new one is started after leaving
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Twice()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(away)).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(awayAgain)).Return(nil).Once()
+	haukClient.On("StopSession", "session").Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Twice()
+	notifier.On("Notify", notification.EventSessionStopped, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		PrivacyZones: []PrivacyZone{{
+			Name: "Work",
+			Area: geo.Polygon{Vertices: []geo.Point{
+				{Latitude: 47.69, Longitude: 12.9},
+				{Latitude: 47.69, Longitude: 13},
+				{Latitude: 47.71, Longitude: 13},
+				{Latitude: 47.71, Longitude: 12.9},
+			}},
+			PauseSession: true,
+		}},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_PrivacyZonePauseSessionWithoutSession(t *testing.T) {
+	// given: device without session at work and away again
+	work := createValidLocation()
+	work.Latitude = 47.7
+	work.Time = time.Unix(1, 0)
+	away := createValidLocation()
+	away.Time = time.Unix(2, 0)
+	events := make(chan source.Event, 2)
+	for _, location := range []source.Location{work, away} {
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	}
+	close(events)
+
+	// given: no session is created
+	haukClient := new(MockHaukClient)
+	notifier := new(MockNotifier)
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: false,
+		PrivacyZones: []PrivacyZone{{
+			Name:         "Work",
+			Area:         geo.Circle{Center: geo.Point{Latitude: 47.7, Longitude: work.Longitude}, Radius: 100},
+			PauseSession: true,
+		}},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
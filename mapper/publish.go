@@ -0,0 +1,75 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/mqtt"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+// Publisher publishes messages to the devices, e.g. via MQTT
+type Publisher interface {
+	Publish(topic string, payload []byte, retained bool) error
+}
+
+// PublishConfig holds the configuration of publishing sessions back to the devices
+type PublishConfig struct {
+	// Session publishes the current session retained to <topic>/hauk
+	Session bool
+	// Cmd sends the session link to the OwnTracks app via <topic>/cmd
+	Cmd bool
+	// Password includes the end-to-end password in the published links
+	Password bool
+}
+
+// sessionMessage is published retained to <topic>/hauk while a session is active
+type sessionMessage struct {
+	URL     string    `json:"url"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+func (t *Mapper) publishSession(topic string, entry store.Entry) {
+	if t.publisher == nil {
+		return
+	}
+	// Everyone allowed to subscribe to the topic can read the link, so the password is left out by default
+	shareURL := entry.Session.URL
+	if t.config.Publish.Password {
+		shareURL = getShareURL(entry.Session, t.config.device(topic).e2ePassword)
+	}
+	if t.config.Publish.Session {
+		payload, err := json.Marshal(sessionMessage{URL: shareURL, Created: entry.Created, Expires: entry.Expires})
+		if err != nil {
+			log.Printf("Could not serialize session of %s: %v", topic, err)
+		} else {
+			t.publish(topic+mqtt.TopicSuffixSession, payload, true)
+		}
+	}
+	if t.config.Publish.Cmd {
+		payload, err := mqtt.NewActionCommand(fmt.Sprintf("Sharing location via Hauk: %s", shareURL), shareURL)
+		if err != nil {
+			log.Printf("Could not serialize command for %s: %v", topic, err)
+		} else {
+			t.publish(topic+mqtt.TopicSuffixCmd, payload, false)
+		}
+	}
+}
+
+// unpublishSession removes the retained session of the topic
+func (t *Mapper) unpublishSession(topic string) {
+	if t.publisher == nil || !t.config.Publish.Session {
+		return
+	}
+	// An empty retained message deletes the retained message of the topic
+	t.publish(topic+mqtt.TopicSuffixSession, []byte{}, true)
+}
+
+func (t *Mapper) publish(topic string, payload []byte, retained bool) {
+	if err := t.publisher.Publish(topic, payload, retained); err != nil {
+		log.Printf("Could not publish to %s: %v", topic, err)
+	}
+}
@@ -0,0 +1,107 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// locationQueue buffers location events per topic which could not be delivered to Hauk.
+// It is not safe for concurrent use, the mapper guards it with its mutex.
+type locationQueue struct {
+	config QueueConfig
+	events map[string][]source.Event
+}
+
+func newLocationQueue(config QueueConfig) (*locationQueue, error) {
+	queue := &locationQueue{config: config, events: make(map[string][]source.Event)}
+	if config.Path == "" {
+		return queue, nil
+	}
+	data, err := ioutil.ReadFile(config.Path)
+	if os.IsNotExist(err) {
+		return queue, nil
+	} else if err != nil {
+		return queue, fmt.Errorf("Could not read location queue %s: %w", config.Path, err)
+	}
+	if err = json.Unmarshal(data, &queue.events); err != nil {
+		return queue, fmt.Errorf("Could not parse location queue %s: %w", config.Path, err)
+	}
+	return queue, nil
+}
+
+// push appends the event to the queue of its topic.
+// It returns false if an older event had to be dropped to make room.
+func (t *locationQueue) push(event source.Event) bool {
+	events := append(t.events[event.Topic], event)
+	dropped := false
+	if t.config.Mode == QueueModeLatest {
+		dropped = len(events) > 1
+		events = events[len(events)-1:]
+	} else if t.config.Size > 0 && len(events) > t.config.Size {
+		dropped = true
+		events = events[len(events)-t.config.Size:]
+	}
+	t.events[event.Topic] = events
+	return !dropped
+}
+
+func (t *locationQueue) peek(topic string) (source.Event, bool) {
+	events := t.events[topic]
+	if len(events) == 0 {
+		return source.Event{}, false
+	}
+	return events[0], true
+}
+
+func (t *locationQueue) replaceHead(event source.Event) {
+	if t.contains(event.Topic) {
+		t.events[event.Topic][0] = event
+	}
+}
+
+func (t *locationQueue) pop(topic string) {
+	events := t.events[topic]
+	if len(events) <= 1 {
+		delete(t.events, topic)
+		return
+	}
+	t.events[topic] = events[1:]
+}
+
+func (t *locationQueue) contains(topic string) bool {
+	return len(t.events[topic]) > 0
+}
+
+func (t *locationQueue) topics() []string {
+	topics := make([]string, 0, len(t.events))
+	for topic := range t.events {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+func (t *locationQueue) save() error {
+	if t.config.Path == "" {
+		return nil
+	}
+	data, err := json.Marshal(t.events)
+	if err != nil {
+		return fmt.Errorf("Could not serialize location queue: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(t.config.Path), 0700); err != nil {
+		return fmt.Errorf("Could not create location queue directory: %w", err)
+	}
+	tmpPath := t.config.Path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("Could not write location queue %s: %w", tmpPath, err)
+	}
+	if err = os.Rename(tmpPath, t.config.Path); err != nil {
+		return fmt.Errorf("Could not write location queue %s: %w", t.config.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package mapper
+
+import (
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// RateLimitConfig holds the configuration of limiting locations to one per session interval
+type RateLimitConfig struct {
+	Enabled bool
+	// Interval is used for devices without their own session interval
+	Interval time.Duration
+}
+
+// rateLimit holds the rate limiting state of a device
+type rateLimit struct {
+	forwarded time.Time
+	pending   *source.Event
+	timer     *time.Timer
+}
+
+// limitRate forwards at most one location per interval for each device.
+// Locations arriving within the interval are held back and only the latest one is forwarded at the end of it.
+// It returns true if the location was held back.
+func (t *Mapper) limitRate(event source.Event) bool {
+	interval := t.rateLimitInterval(event.Topic)
+	if interval <= 0 {
+		return false
+	}
+
+	limit, exists := t.rateLimits[event.Topic]
+	if !exists {
+		limit = &rateLimit{}
+		t.rateLimits[event.Topic] = limit
+	}
+	now := time.Now()
+	// Manual locations are forwarded immediately, as they may be meant to start a session.
+	// The held back location is older, forwarding it later would move the marker back.
+	if event.Manual {
+		if limit.pending != nil {
+			metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonRateLimited).Inc()
+		}
+		limit.stop()
+		limit.forwarded = now
+		return false
+	}
+	if limit.timer == nil && now.Sub(limit.forwarded) >= interval {
+		limit.forwarded = now
+		return false
+	}
+
+	if limit.pending != nil {
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonRateLimited).Inc()
+	}
+	limit.pending = &event
+	if limit.timer == nil {
+		topic := event.Topic
+		limit.timer = time.AfterFunc(limit.forwarded.Add(interval).Sub(now), func() {
+			t.flushRateLimited(topic)
+		})
+	}
+	return true
+}
+
+// flushRateLimited forwards the location held back for the topic
+func (t *Mapper) flushRateLimited(topic string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	limit, exists := t.rateLimits[topic]
+	if !exists || limit.pending == nil {
+		return
+	}
+	event := *limit.pending
+	limit.pending = nil
+	limit.timer = nil
+	limit.forwarded = time.Now()
+	t.forward(event)
+}
+
+// flushAllRateLimited forwards the locations held back for all topics, so none is lost when the mapper stops
+func (t *Mapper) flushAllRateLimited() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, limit := range t.rateLimits {
+		pending := limit.pending
+		limit.stop()
+		if pending != nil {
+			t.forward(*pending)
+		}
+	}
+}
+
+// stop discards the held back location and its timer
+func (t *rateLimit) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.pending = nil
+}
+
+func (t *Mapper) rateLimitInterval(topic string) time.Duration {
+	if !t.config.RateLimit.Enabled {
+		return 0
+	}
+	if interval := t.config.device(topic).sessionInterval; interval > 0 {
+		return interval
+	}
+	return t.config.RateLimit.Interval
+}
@@ -0,0 +1,121 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestRun_RateLimit(t *testing.T) {
+	// given: three locations within one interval
+	var locations []source.Location
+	events := make(chan source.Event, 3)
+	for i := 0; i < 3; i++ {
+		location := createValidLocation()
+		location.Time = location.Time.Add(time.Duration(i) * time.Second)
+		locations = append(locations, location)
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	}
+
+	// given: first location is posted immediately, the last one at the end of the interval
+	flushed := make(chan struct{})
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(locations[0])).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(locations[2])).Return(nil).Once().Run(func(mock.Arguments) {
+		close(flushed)
+	})
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		RateLimit:        RateLimitConfig{Enabled: true, Interval: 100 * time.Millisecond},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	done := make(chan struct{})
+	go func() {
+		mapper.Run(events)
+		close(done)
+	}()
+
+	// then
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("Latest location was not flushed at the end of the interval")
+	}
+	close(events)
+	<-done
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_RateLimitManual(t *testing.T) {
+	// given: a location, one held back and a newer manual one
+	var locations []source.Location
+	events := make(chan source.Event, 3)
+	for i := 0; i < 3; i++ {
+		location := createValidLocation()
+		location.Time = location.Time.Add(time.Duration(i) * time.Second)
+		locations = append(locations, location)
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location, Manual: i == 2}
+	}
+	close(events)
+
+	// given: held back location is discarded, so it is not posted after the manual one
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(locations[0])).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(locations[2])).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		RateLimit:        RateLimitConfig{Enabled: true, Interval: time.Hour},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_RateLimitFlushedOnStop(t *testing.T) {
+	// given: two locations within one long interval
+	var locations []source.Location
+	events := make(chan source.Event, 2)
+	for i := 0; i < 2; i++ {
+		location := createValidLocation()
+		location.Time = location.Time.Add(time.Duration(i) * time.Second)
+		locations = append(locations, location)
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	}
+	close(events)
+
+	// given: held back location is posted when the mapper stops
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(locations[0])).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(locations[1])).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		RateLimit:        RateLimitConfig{Enabled: true, Interval: time.Hour},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
@@ -0,0 +1,54 @@
+package mapper
+
+import (
+	"log"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+)
+
+// silence notices when a device stops sending locations
+type silence struct {
+	lastSeen time.Time
+	timer    *time.Timer
+}
+
+// watchSilence restarts the timer of the device, which fires if no further location is received
+func (t *Mapper) watchSilence(topic string) {
+	silentAfter := t.config.device(topic).silentAfter
+	if silentAfter <= 0 {
+		return
+	}
+	now := time.Now()
+	if watched, exists := t.silences[topic]; exists {
+		watched.lastSeen = now
+		watched.timer.Reset(silentAfter)
+		return
+	}
+	t.silences[topic] = &silence{lastSeen: now, timer: time.AfterFunc(silentAfter, func() {
+		t.handleSilence(topic)
+	})}
+}
+
+// handleSilence notifies that the device went silent, if it still has a session
+func (t *Mapper) handleSilence(topic string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	device := t.config.device(topic)
+	watched := t.silences[topic]
+	// A location may have arrived while waiting for the lock
+	if time.Since(watched.lastSeen) < device.silentAfter {
+		return
+	}
+	entry, sessionExists := t.sessions.Get(topic)
+	if !sessionExists || time.Now().After(entry.Expires) {
+		return
+	}
+	log.Printf("No location of %s received since %v", topic, watched.lastSeen)
+	t.notifier.Notify(notification.Event{
+		Type:     notification.EventDeviceSilent,
+		Device:   device.notification,
+		Session:  t.notificationSession(topic, entry, getShareURL(entry.Session, device.e2ePassword)),
+		LastSeen: watched.lastSeen,
+	})
+}
@@ -0,0 +1,48 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestRun_DeviceSilent(t *testing.T) {
+	// given: a single location
+	location := createValidLocation()
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	close(events)
+
+	// given: device is reported silent after the session has been created
+	silent := make(chan struct{})
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{Duration: time.Hour}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location)).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+	notifier.On("Notify", notification.EventDeviceSilent, notification.Device{Topic: "whatevs"}, "URL").Once().Run(func(mock.Arguments) {
+		close(silent)
+	})
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		SessionDuration:  time.Hour,
+		SilentAfter:      50 * time.Millisecond,
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	select {
+	case <-silent:
+	case <-time.After(time.Second):
+		t.Fatal("Silent device was not reported")
+	}
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
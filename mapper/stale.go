@@ -0,0 +1,85 @@
+package mapper
+
+import (
+	"log"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// StaleConfig holds the configuration of dropping stale, duplicate and out-of-order locations
+type StaleConfig struct {
+	DropOutOfOrder bool
+	DropDuplicates bool
+	// MaxAge of locations relative to now, 0 disables the check
+	MaxAge time.Duration
+	// Backlog is BacklogDrop or BacklogTrail and applies to locations older than MaxAge
+	Backlog string
+}
+
+type timeCheck int
+
+const (
+	timeOK timeCheck = iota
+	timeSkip
+	timeBacklog
+)
+
+// checkTime compares the time of the location with the last posted location of the device and the maximum age.
+// Locations without time are never skipped.
+func (t *Mapper) checkTime(event source.Event) timeCheck {
+	locationTime := event.Location.Time
+	if locationTime.IsZero() {
+		return timeOK
+	}
+
+	lastLocation, posted := t.lastLocations[event.Topic]
+	lastPosted := lastLocation.Time
+	posted = posted && !lastPosted.IsZero()
+	if posted && t.config.Stale.DropDuplicates && locationTime.Equal(lastPosted) {
+		log.Printf("Location of %s from %v has already been posted, skipping\n", event.Topic, locationTime)
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonDuplicate).Inc()
+		return timeSkip
+	}
+	if posted && t.config.Stale.DropOutOfOrder && locationTime.Before(lastPosted) {
+		log.Printf("Location of %s from %v is older than the last posted one, skipping\n", event.Topic, locationTime)
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonOutOfOrder).Inc()
+		return timeSkip
+	}
+	if t.config.Stale.MaxAge > 0 && time.Since(locationTime) > t.config.Stale.MaxAge {
+		if t.config.Stale.Backlog == BacklogTrail {
+			return timeBacklog
+		}
+		log.Printf("Location of %s from %v is stale, skipping\n", event.Topic, locationTime)
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonStale).Inc()
+		return timeSkip
+	}
+	return timeOK
+}
+
+// deliverBacklog posts a stale location to the current session of the device, so it shows up in the trail.
+// It neither creates sessions nor triggers geofences.
+func (t *Mapper) deliverBacklog(event source.Event) {
+	entry, sessionExists := t.sessions.Get(event.Topic)
+	if !sessionExists {
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonStale).Inc()
+		return
+	}
+	if err := t.haukClient.PostLocation(entry.Session, createLocationParams(event.Location)); err != nil {
+		log.Printf("Could not post stale location of %s, skipping: %v\n", event.Topic, err)
+		metrics.LocationsSkipped.WithLabelValues(event.Topic, metrics.ReasonStale).Inc()
+		return
+	}
+	metrics.LocationsPosted.WithLabelValues(event.Topic).Inc()
+	t.setLastLocation(event)
+}
+
+// setLastLocation remembers the posted location, unless it is older than the last one
+func (t *Mapper) setLastLocation(event source.Event) {
+	last, posted := t.lastLocations[event.Topic]
+	if posted && !last.Time.IsZero() && event.Location.Time.Before(last.Time) {
+		return
+	}
+	t.lastLocations[event.Topic] = event.Location
+}
@@ -0,0 +1,96 @@
+package mapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+	"github.com/tuffnerdstuff/hauk-snitch/notification"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+	"github.com/tuffnerdstuff/hauk-snitch/store"
+)
+
+func TestRun_DropDuplicateAndOutOfOrder(t *testing.T) {
+	// given: second location is a duplicate, third one is older than the first
+	location1 := createValidLocation()
+	location1.Time = time.Unix(2, 0)
+	location3 := createValidLocation()
+	location3.Time = time.Unix(1, 0)
+	location4 := createValidLocation()
+	location4.Time = time.Unix(3, 0)
+	events := make(chan source.Event, 4)
+	for _, location := range []source.Location{location1, location1, location3, location4} {
+		events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: location}
+	}
+	close(events)
+
+	// given: only first and last location are posted
+	haukClient := new(MockHaukClient)
+	haukClient.On("CreateSession", hauk.SessionOptions{}).Return(hauk.Session{SID: "session", URL: "URL"}, nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location1)).Return(nil).Once()
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(location4)).Return(nil).Once()
+	notifier := new(MockNotifier)
+	notifier.On("Notify", notification.EventNewSession, notification.Device{Topic: "whatevs"}, "URL").Once()
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Stale:            StaleConfig{DropOutOfOrder: true, DropDuplicates: true, Backlog: BacklogDrop},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_StaleBacklog(t *testing.T) {
+	// given: stale locations of a device without session and of a device with session
+	stale := createValidLocation()
+	stale.Time = time.Now().Add(-time.Hour)
+	events := make(chan source.Event, 2)
+	events <- source.Event{Topic: "new", Type: source.TypeLocation, Location: stale}
+	events <- source.Event{Topic: "active", Type: source.TypeLocation, Location: stale, Manual: true}
+	close(events)
+	sessions := store.NewMemory()
+	sessions.Put("active", store.Entry{Session: hauk.Session{SID: "session"}, Expires: time.Now().Add(time.Hour)})
+
+	// given: no session is started, stale location is only posted to the existing session
+	haukClient := new(MockHaukClient)
+	haukClient.On("PostLocation", "session", getExpectedLocationValues(stale)).Return(nil).Once()
+	notifier := new(MockNotifier)
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto:   true,
+		SessionStartManual: true,
+		Stale:              StaleConfig{MaxAge: 10 * time.Minute, Backlog: BacklogTrail},
+	}, haukClient, notifier, sessions, nil)
+	mapper.Run(events)
+
+	// then
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
+
+func TestRun_StaleDropped(t *testing.T) {
+	// given
+	stale := createValidLocation()
+	stale.Time = time.Now().Add(-time.Hour)
+	events := make(chan source.Event, 1)
+	events <- source.Event{Topic: "whatevs", Type: source.TypeLocation, Location: stale}
+	close(events)
+	haukClient := new(MockHaukClient)
+	notifier := new(MockNotifier)
+
+	// when
+	mapper := New(Config{
+		SessionStartAuto: true,
+		Stale:            StaleConfig{MaxAge: 10 * time.Minute, Backlog: BacklogDrop},
+	}, haukClient, notifier, store.NewMemory(), nil)
+	mapper.Run(events)
+
+	// then: nothing is posted
+	haukClient.AssertExpectations(t)
+	notifier.AssertExpectations(t)
+}
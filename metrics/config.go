@@ -0,0 +1,8 @@
+package metrics
+
+// Config holds the configuration of the Prometheus metrics endpoint
+type Config struct {
+	Enabled bool
+	Host    string
+	Port    int
+}
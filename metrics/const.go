@@ -0,0 +1,82 @@
+package metrics
+
+// EndpointMetrics is the path of the Prometheus metrics endpoint
+const EndpointMetrics string = "/metrics"
+
+// EndpointHealth is the path of the health endpoint
+const EndpointHealth string = "/health"
+
+// LabelTopic is the label for the mqtt topic (device)
+const LabelTopic string = "topic"
+
+// LabelReason is the label for the reason a location was skipped
+const LabelReason string = "reason"
+
+// LabelMode is the label for the privacy zone mode
+const LabelMode string = "mode"
+
+// LabelEndpoint is the label for the Hauk API endpoint
+const LabelEndpoint string = "endpoint"
+
+// LabelStatus is the label for the HTTP status code of a response
+const LabelStatus string = "status"
+
+// LabelChannel is the label for the notification channel
+const LabelChannel string = "channel"
+
+// LabelResult is the label for the result of an operation
+const LabelResult string = "result"
+
+// LabelCommand is the label for the name of a command
+const LabelCommand string = "command"
+
+// ReasonInvalid means the message was not a valid location
+const ReasonInvalid string = "invalid"
+
+// ReasonNoSession means there was no session and none could be created
+const ReasonNoSession string = "no_session"
+
+// ReasonPostFailed means posting the location to Hauk failed
+const ReasonPostFailed string = "post_failed"
+
+// ReasonQueueFull means the location was dropped from the full queue
+const ReasonQueueFull string = "queue_full"
+
+// ReasonGeofence means the device is inside a region where sharing is paused
+const ReasonGeofence string = "geofence"
+
+// ReasonDuplicate means the location has the same time as the last posted one
+const ReasonDuplicate string = "duplicate"
+
+// ReasonOutOfOrder means the location is older than the last posted one
+const ReasonOutOfOrder string = "out_of_order"
+
+// ReasonStale means the location is older than the maximum age
+const ReasonStale string = "stale"
+
+// ReasonInaccurate means the accuracy of the location exceeds the maximum
+const ReasonInaccurate string = "inaccurate"
+
+// ReasonJitter means the device moved less than the minimum distance
+const ReasonJitter string = "jitter"
+
+// ReasonImplausible means the device would have moved faster than the maximum speed
+const ReasonImplausible string = "implausible"
+
+// StatusError is the status of requests which did not get a response
+const StatusError string = "error"
+
+// ResultSuccess is the result of successful operations
+const ResultSuccess string = "success"
+
+// ResultFailure is the result of failed operations
+const ResultFailure string = "failure"
+
+// ResultDropped is the result of operations which were given up
+const ResultDropped string = "dropped"
+
+// ReasonRateLimited means the location was replaced by a newer one within the same interval
+const ReasonRateLimited string = "rate_limited"
+
+// ReasonPrivacy means the location is inside a privacy zone
+const ReasonPrivacy string = "privacy"
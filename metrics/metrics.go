@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "hauksnitch"
+
+// MqttConnected is 1 while the mqtt client is connected to the broker
+var MqttConnected = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "mqtt",
+	Name:      "connected",
+	Help:      "Whether the mqtt client is connected to the broker (1) or not (0)",
+})
+
+// MessagesReceived counts all messages received from the mqtt broker
+var MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mqtt",
+	Name:      "messages_received_total",
+	Help:      "Number of messages received from the mqtt broker",
+}, []string{LabelTopic})
+
+// MessagesUnparsable counts messages which could not be parsed or decrypted
+var MessagesUnparsable = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mqtt",
+	Name:      "messages_unparsable_total",
+	Help:      "Number of messages which could not be parsed or decrypted",
+}, []string{LabelTopic})
+
+// LocationsPosted counts locations successfully posted to Hauk
+var LocationsPosted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "locations_posted_total",
+	Help:      "Number of locations posted to Hauk",
+}, []string{LabelTopic})
+
+// LocationsSkipped counts messages which were not posted to Hauk
+var LocationsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "locations_skipped_total",
+	Help:      "Number of messages which were not posted to Hauk",
+}, []string{LabelTopic, LabelReason})
+
+// LocationsMasked counts locations which were masked by a privacy zone before being posted
+var LocationsMasked = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "locations_masked_total",
+	Help:      "Number of locations masked by a privacy zone",
+}, []string{LabelTopic, LabelMode})
+
+// LocationsSmoothed counts locations which were corrected by the filter before being posted
+var LocationsSmoothed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "locations_smoothed_total",
+	Help:      "Number of locations corrected by the filter",
+}, []string{LabelTopic, LabelReason})
+
+// LocationsQueued counts locations queued because Hauk was unreachable
+var LocationsQueued = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "locations_queued_total",
+	Help:      "Number of locations queued because Hauk was unreachable",
+}, []string{LabelTopic})
+
+// SessionsCreated counts created Hauk sessions
+var SessionsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "sessions_created_total",
+	Help:      "Number of Hauk sessions created",
+}, []string{LabelTopic})
+
+// SessionsExpired counts Hauk sessions which expired
+var SessionsExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "mapper",
+	Name:      "sessions_expired_total",
+	Help:      "Number of Hauk sessions which expired",
+}, []string{LabelTopic})
+
+// HaukRequests counts requests to the Hauk API by endpoint and status code
+var HaukRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "hauk",
+	Name:      "requests_total",
+	Help:      "Number of requests to the Hauk API",
+}, []string{LabelEndpoint, LabelStatus})
+
+// HaukRequestDuration observes the latency of requests to the Hauk API by endpoint
+var HaukRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "hauk",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of requests to the Hauk API",
+	Buckets:   prometheus.DefBuckets,
+}, []string{LabelEndpoint})
+
+// NotificationsSent counts notifications by channel and result
+var NotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "notification",
+	Name:      "sent_total",
+	Help:      "Number of notifications sent",
+}, []string{LabelChannel, LabelResult})
+
+// CommandsExecuted counts commands received via MQTT by command and result
+var CommandsExecuted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "command",
+	Name:      "executed_total",
+	Help:      "Number of commands executed",
+}, []string{LabelCommand, LabelResult})
+
+// HealthCheck returns an error if a component is not healthy
+type HealthCheck func() error
+
+// Serve serves the metrics and health endpoints, it blocks until the server fails
+func Serve(config Config, healthChecks map[string]HealthCheck) error {
+	address := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	log.Printf("Serving metrics on %s%s\n", address, EndpointMetrics)
+	mux := http.NewServeMux()
+	mux.Handle(EndpointMetrics, promhttp.Handler())
+	mux.Handle(EndpointHealth, healthHandler(healthChecks))
+	return http.ListenAndServe(address, mux)
+}
+
+// healthHandler responds with the status of each component, 503 if any is unhealthy
+func healthHandler(healthChecks map[string]HealthCheck) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		statusCode := http.StatusOK
+		status := make(map[string]string, len(healthChecks))
+		for name, check := range healthChecks {
+			if err := check(); err != nil {
+				statusCode = http.StatusServiceUnavailable
+				status[name] = err.Error()
+			} else {
+				status[name] = "ok"
+			}
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(statusCode)
+		json.NewEncoder(writer).Encode(status)
+	})
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler_Healthy(t *testing.T) {
+	// given
+	handler := healthHandler(map[string]HealthCheck{"mqtt": func() error { return nil }})
+	response := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, EndpointHealth, nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"mqtt":"ok"}`, response.Body.String())
+}
+
+func TestHealthHandler_Unhealthy(t *testing.T) {
+	// given
+	handler := healthHandler(map[string]HealthCheck{
+		"mqtt":  func() error { return fmt.Errorf("Not connected to mqtt broker") },
+		"other": func() error { return nil },
+	})
+	response := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, EndpointHealth, nil))
+
+	// then
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.JSONEq(t, `{"mqtt":"Not connected to mqtt broker","other":"ok"}`, response.Body.String())
+}
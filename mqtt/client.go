@@ -2,68 +2,232 @@ package mqtt
 
 import (
 	"crypto/sha256"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
 )
 
 // Client provides an mqtt client
 type Client struct {
-	Messages   chan Message
-	config     Config
-	pahoClient paho.Client
+	events         chan source.Event
+	config         Config
+	pahoClient     paho.Client
+	parser         *PayloadParser
+	done           chan struct{}
+	disconnectOnce sync.Once
+	connected      int32
+	subscribed     int32
+	// subscriptions holds additional subscriptions and their handlers
+	subscriptions      map[string]func(topic string, payload []byte)
+	subscriptionsMutex sync.Mutex
 }
 
 // New returns an instance of an mqtt client
 func New(config Config) *Client {
-	return &Client{config: config, Messages: make(chan Message)}
+	return &Client{
+		config:        config,
+		events:        make(chan source.Event),
+		done:          make(chan struct{}),
+		parser:        NewPayloadParser(config),
+		subscriptions: make(map[string]func(topic string, payload []byte)),
+	}
+}
+
+// Events returns the events received from the broker
+func (t *Client) Events() <-chan source.Event {
+	return t.events
 }
 
-// Connect connects to mqtt broker using the given config
+// Connect connects to mqtt broker using the given config.
+// If the broker is not reachable, it retries with exponential backoff.
+// Subscriptions are (re-)established each time the connection is (re-)established.
 func (t *Client) Connect() {
 	log.Printf("Connecting to mqtt broker %s\n", formatBrokerURL(t.config.Host, t.config.Port, t.config.IsTLS))
 	t.initClient()
 	t.connectClient()
-	t.subscribeClient()
 }
 
-// Disconnect closes the Messages channel and disconnects the mqtt client
+// Disconnect closes the events channel and disconnects the mqtt client
 func (t *Client) Disconnect() {
-	t.pahoClient.Disconnect(250)
-	close(t.Messages)
+	t.disconnectOnce.Do(func() {
+		close(t.done)
+		t.pahoClient.Disconnect(250)
+		close(t.events)
+	})
+}
+
+// Publish sends the payload to the topic.
+// It only returns errors which occur immediately (e.g. not connected), it does not wait for the broker,
+// as waiting within a message handler would block the client. Later failures are logged.
+func (t *Client) Publish(topic string, payload []byte, retained bool) error {
+	token := t.pahoClient.Publish(topic, byte(1), retained, payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("Error while publishing to %s: %w", topic, err)
+		}
+		return nil
+	default:
+	}
+	go func() {
+		if !token.WaitTimeout(publishTimeout) {
+			log.Printf("Timeout while publishing to %s\n", topic)
+		} else if err := token.Error(); err != nil {
+			log.Printf("Error while publishing to %s: %v\n", topic, err)
+		}
+	}()
+	return nil
+}
+
+// Subscribe additionally subscribes to the topic, its messages are passed to the handler instead of the events.
+// The subscription is re-established on reconnect like the subscription of the location topic.
+func (t *Client) Subscribe(topic string, handler func(topic string, payload []byte)) {
+	t.subscriptionsMutex.Lock()
+	t.subscriptions[topic] = handler
+	t.subscriptionsMutex.Unlock()
+	if t.pahoClient != nil && t.pahoClient.IsConnected() {
+		t.subscribe(topic, handler)
+	}
+}
+
+// CheckHealth returns an error if the client is not connected to the broker or not subscribed
+func (t *Client) CheckHealth() error {
+	if atomic.LoadInt32(&t.connected) == 0 {
+		return fmt.Errorf("Not connected to mqtt broker")
+	}
+	if atomic.LoadInt32(&t.subscribed) == 0 {
+		return fmt.Errorf("Not subscribed to topic %s", t.config.Topic)
+	}
+	return nil
 }
 
 func (t *Client) initClient() {
 	opts := paho.NewClientOptions()
 	opts.AddBroker(formatBrokerURL(t.config.Host, t.config.Port, t.config.IsTLS))
 	opts.SetClientID("hauk-snitch" + generateHash())
+	if t.config.IsTLS {
+		tlsConfig, err := newTLSConfig(t.config)
+		if err != nil {
+			panic(fmt.Errorf("Error in mqtt TLS config: %w", err))
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
 	if !t.config.IsAnonymous {
 		opts.SetUsername(t.config.User)
 		opts.SetPassword(t.config.Password)
 	}
 	opts.SetCleanSession(false)
-	// FIXME: process message
+	opts.SetAutoReconnect(true)
+	opts.SetMaxReconnectInterval(t.config.ReconnectMax)
+	opts.SetOnConnectHandler(func(client paho.Client) {
+		log.Println("Connected to mqtt broker")
+		t.setConnected(true)
+		t.subscribeClient()
+		t.subscribeAdditional()
+	})
+	opts.SetConnectionLostHandler(func(client paho.Client, err error) {
+		log.Printf("Lost connection to mqtt broker: %v\n", err)
+		t.setConnected(false)
+	})
+	opts.SetReconnectingHandler(func(client paho.Client, opts *paho.ClientOptions) {
+		log.Println("Reconnecting to mqtt broker")
+	})
 	opts.SetDefaultPublishHandler(func(client paho.Client, msg paho.Message) {
-		jsonMap := make(map[string]interface{})
-		json.Unmarshal(msg.Payload(), &jsonMap)
-		t.Messages <- Message{Topic: msg.Topic(), Body: jsonMap}
+		metrics.MessagesReceived.WithLabelValues(msg.Topic()).Inc()
+		event, err := t.parser.ParseEvent(msg.Topic(), msg.Payload())
+		var unsupportedTypeError *UnsupportedTypeError
+		if errors.As(err, &unsupportedTypeError) {
+			log.Printf("Skipping message on %s: %v\n", msg.Topic(), err)
+			return
+		} else if err != nil {
+			metrics.MessagesUnparsable.WithLabelValues(msg.Topic()).Inc()
+			log.Printf("Could not parse message on %s, skipping: %v\n", msg.Topic(), err)
+			return
+		}
+		select {
+		case t.events <- event:
+		case <-t.done:
+		}
 	})
 	t.pahoClient = paho.NewClient(opts)
 }
 
 func (t *Client) connectClient() {
-	if token := t.pahoClient.Connect(); token.Wait() && token.Error() != nil {
-		panic(fmt.Errorf("Error while connecting to mqtt broker: %w", token.Error()))
+	backoff := t.config.ConnectRetryInitial
+	for attempt := 1; ; attempt++ {
+		token := t.pahoClient.Connect()
+		if token.Wait() && token.Error() == nil {
+			return
+		}
+		if t.config.ConnectRetries > 0 && attempt >= t.config.ConnectRetries {
+			panic(fmt.Errorf("Error while connecting to mqtt broker: %w", token.Error()))
+		}
+
+		log.Printf("Could not connect to mqtt broker (attempt %d), retrying in %v: %v\n", attempt, backoff, token.Error())
+		select {
+		case <-t.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > t.config.ConnectRetryMax {
+			backoff = t.config.ConnectRetryMax
+		}
 	}
 }
 
 func (t *Client) subscribeClient() {
-	// FIXME: topic+qos configurable
-	if token := t.pahoClient.Subscribe(t.config.Topic, byte(0), nil); token.Wait() && token.Error() != nil {
-		panic(fmt.Errorf("Error while subscribing to topic: %w", token.Error()))
+	// FIXME: qos configurable
+	topics := map[string]byte{t.config.Topic: byte(0)}
+	// Payloads of other publishers may be published outside of the location topic
+	for _, mapping := range t.config.Mappings {
+		topics[mapping.Topic] = byte(0)
+	}
+	if token := t.pahoClient.SubscribeMultiple(topics, nil); token.Wait() && token.Error() != nil {
+		log.Printf("Error while subscribing to topic %s: %v\n", t.config.Topic, token.Error())
+		atomic.StoreInt32(&t.subscribed, 0)
+		return
+	}
+	for topic := range topics {
+		log.Printf("Subscribed to topic %s\n", topic)
+	}
+	atomic.StoreInt32(&t.subscribed, 1)
+}
+
+func (t *Client) subscribeAdditional() {
+	t.subscriptionsMutex.Lock()
+	defer t.subscriptionsMutex.Unlock()
+	for topic, handler := range t.subscriptions {
+		t.subscribe(topic, handler)
+	}
+}
+
+func (t *Client) subscribe(topic string, handler func(topic string, payload []byte)) {
+	token := t.pahoClient.Subscribe(topic, byte(1), func(client paho.Client, msg paho.Message) {
+		handler(msg.Topic(), msg.Payload())
+	})
+	if token.Wait() && token.Error() != nil {
+		log.Printf("Error while subscribing to topic %s: %v\n", topic, token.Error())
+		return
+	}
+	log.Printf("Subscribed to topic %s\n", topic)
+}
+
+func (t *Client) setConnected(connected bool) {
+	if connected {
+		atomic.StoreInt32(&t.connected, 1)
+		metrics.MqttConnected.Set(1)
+	} else {
+		atomic.StoreInt32(&t.connected, 0)
+		atomic.StoreInt32(&t.subscribed, 0)
+		metrics.MqttConnected.Set(0)
 	}
 }
 
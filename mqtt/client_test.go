@@ -0,0 +1,23 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHealth(t *testing.T) {
+	// given
+	client := New(Config{Topic: "owntracks/+/+"})
+
+	// then: unhealthy until connected and subscribed
+	assert.Error(t, client.CheckHealth())
+	client.setConnected(true)
+	assert.Error(t, client.CheckHealth())
+	client.subscribed = 1
+	assert.NoError(t, client.CheckHealth())
+
+	// then: unhealthy again when connection is lost
+	client.setConnected(false)
+	assert.Error(t, client.CheckHealth())
+}
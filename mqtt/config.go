@@ -1,12 +1,45 @@
 package mqtt
 
+import (
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
 // Config holds configuration for MqttClient
 type Config struct {
-	Host        string
-	Port        int
-	Topic       string
-	User        string
-	Password    string
-	IsTLS       bool
-	IsAnonymous bool
+	Enabled             bool
+	Host                string
+	Port                int
+	Topic               string
+	User                string
+	Password            string
+	IsTLS               bool
+	IsInsecure          bool
+	CAFile              string
+	CertFile            string
+	KeyFile             string
+	ServerName          string
+	IsAnonymous         bool
+	EncryptionKey       string
+	EncryptionKeys      []EncryptionKey
+	ConnectRetries      int
+	ConnectRetryInitial time.Duration
+	ConnectRetryMax     time.Duration
+	ReconnectMax        time.Duration
+	Mappings            []Mapping
+}
+
+// EncryptionKey is the OwnTracks encryption key for all topics matching the topic pattern
+type EncryptionKey struct {
+	Topic string
+	Key   string
+}
+
+// Mapping maps the payloads of all topics matching the topic pattern to locations
+type Mapping struct {
+	Topic string
+	// Extend adds the fields to the OwnTracks mapping, otherwise payloads are plain locations without "_type"
+	Extend bool
+	Fields source.KeyMapping
 }
@@ -1,5 +1,7 @@
 package mqtt
 
+import "time"
+
 // ParamType is the key for the parameter "type"
 const ParamType string = "_type"
 
@@ -21,9 +23,76 @@ const ParamAccuracy string = "acc"
 // ParamVelocity is the key for the parameter "velocity"
 const ParamVelocity string = "vel"
 
+// ParamBattery is the key for the parameter "battery level" in percent
+const ParamBattery string = "batt"
+
+// ParamBatteryStatus is the key for the parameter "battery status"
+const ParamBatteryStatus string = "bs"
+
+// BatteryStatusUnplugged is a value for the parameter "battery status", the device is not charging
+const BatteryStatusUnplugged = 1
+
+// BatteryStatusCharging is a value for the parameter "battery status", the device is charging
+const BatteryStatusCharging = 2
+
+// BatteryStatusFull is a value for the parameter "battery status", the device is plugged in and fully charged
+const BatteryStatusFull = 3
+
 // ParamTrigger is the key for the parameter "trigger"
 const ParamTrigger string = "t"
 
 // TriggerManual is a value for the parameter "trigger".
 // It means that the location has been sent by the user manually.
 const TriggerManual string = "u"
+
+// TypeEncrypted is a value for the parameter "type".
+// It means that the actual message is encrypted in the parameter "data".
+const TypeEncrypted string = "encrypted"
+
+// ParamData is the key for the parameter "data" of encrypted messages
+const ParamData string = "data"
+
+// TypeLocation is a value for the parameter "type".
+// It means that the message is a location update.
+const TypeLocation string = "location"
+
+// TypeTransition is a value for the parameter "type".
+// It means that the device entered or left a region.
+const TypeTransition string = "transition"
+
+// ParamEvent is the key for the parameter "event" of transitions
+const ParamEvent string = "event"
+
+// ParamDescription is the key for the parameter "description" of transitions (region name)
+const ParamDescription string = "desc"
+
+// EventEnter is a value for the parameter "event", the device entered the region
+const EventEnter string = "enter"
+
+// EventLeave is a value for the parameter "event", the device left the region
+const EventLeave string = "leave"
+
+// TypeCmd is a value for the parameter "type".
+// It means that the message is a command sent to the device.
+const TypeCmd string = "cmd"
+
+// ParamAction is the key for the parameter "action" of commands
+const ParamAction string = "action"
+
+// ParamContent is the key for the parameter "content" of action commands
+const ParamContent string = "content"
+
+// ParamURL is the key for the parameter "url" of action commands
+const ParamURL string = "url"
+
+// ActionAction is a value for the parameter "action", the app shows the content and URL
+const ActionAction string = "action"
+
+// TopicSuffixSession is appended to the device topic to publish its Hauk session
+const TopicSuffixSession string = "/hauk"
+
+// TopicSuffixCmd is appended to the device topic to send commands to the OwnTracks app
+const TopicSuffixCmd string = "/cmd"
+
+// publishTimeout is the maximum time to wait for the broker to acknowledge a publish
+const publishTimeout = 5 * time.Second
@@ -0,0 +1,36 @@
+package mqtt
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const keyLength = 32
+const nonceLength = 24
+
+// decryptPayload decrypts the data of an OwnTracks "encrypted" message.
+// The data is the base64 encoded nonce followed by the libsodium secretbox ciphertext.
+func decryptPayload(data string, secret string) ([]byte, error) {
+	encrypted, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("Could not decode encrypted payload: %w", err)
+	}
+	if len(encrypted) < nonceLength+secretbox.Overhead {
+		return nil, fmt.Errorf("Encrypted payload too short")
+	}
+
+	var nonce [nonceLength]byte
+	copy(nonce[:], encrypted[:nonceLength])
+
+	// OwnTracks pads (or truncates) the secret to the secretbox key length
+	var key [keyLength]byte
+	copy(key[:], secret)
+
+	decrypted, ok := secretbox.Open(nil, encrypted[nonceLength:], &nonce, &key)
+	if !ok {
+		return nil, fmt.Errorf("Could not decrypt payload, wrong key?")
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,101 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// OwnTracksKeyMapping maps the keys of OwnTracks location payloads to location fields
+var OwnTracksKeyMapping = source.KeyMapping{
+	ParamLatitude:  {Field: source.FieldLatitude},
+	ParamLongitude: {Field: source.FieldLongitude},
+	ParamAltitude:  {Field: source.FieldAltitude},
+	ParamAccuracy:  {Field: source.FieldAccuracy},
+	ParamVelocity: {Field: source.FieldSpeed, Convert: func(value float64) float64 {
+		// km/h -> m/s
+		return value / 3.6
+	}},
+	ParamTime:    {Field: source.FieldTime},
+	ParamBattery: {Field: source.FieldBattery},
+	ParamBatteryStatus: {Field: source.FieldCharging, Convert: func(value float64) float64 {
+		switch value {
+		case BatteryStatusUnplugged:
+			return 0
+		case BatteryStatusCharging, BatteryStatusFull:
+			return 1
+		}
+		// unknown
+		return math.NaN()
+	}},
+	// The accuracy mode is not mapped, OwnTracks does not report how a location was determined
+}
+
+// UnsupportedTypeError is returned for OwnTracks payloads which are neither locations nor transitions
+type UnsupportedTypeError struct {
+	Type interface{}
+}
+
+func (t *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("Type %v is not supported", t.Type)
+}
+
+// ParseEvent parses the payload like Parse and converts it into an event of the topic
+func (t *PayloadParser) ParseEvent(topic string, payload []byte) (source.Event, error) {
+	body, err := t.Parse(topic, payload)
+	if err != nil {
+		return source.Event{}, err
+	}
+	return t.toEvent(topic, body)
+}
+
+func (t *PayloadParser) toEvent(topic string, body map[string]interface{}) (source.Event, error) {
+	event := source.Event{Topic: topic}
+	keyMapping := t.keyMapping
+	if mapping, found := t.mappingForTopic(topic); found {
+		if !mapping.Extend {
+			// Plain locations of other publishers
+			location, err := mapping.Fields.Location(body)
+			event.Type = source.TypeLocation
+			event.Location = location
+			return event, err
+		}
+		keyMapping = mapping.Fields
+	}
+
+	switch body[ParamType] {
+	case TypeLocation:
+		location, err := keyMapping.Location(body)
+		if err != nil {
+			return event, err
+		}
+		event.Type = source.TypeLocation
+		event.Location = location
+		event.Manual = body[ParamTrigger] == TriggerManual
+	case TypeTransition:
+		switch body[ParamEvent] {
+		case EventEnter:
+			event.Type = source.TypeEnter
+		case EventLeave:
+			event.Type = source.TypeLeave
+		default:
+			return event, fmt.Errorf("Transition event %v is not supported", body[ParamEvent])
+		}
+		event.Region, _ = body[ParamDescription].(string)
+	default:
+		return event, &UnsupportedTypeError{Type: body[ParamType]}
+	}
+	return event, nil
+}
+
+// NewActionCommand returns an OwnTracks command which shows the content and URL in the app
+func NewActionCommand(content string, URL string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		ParamType:    TypeCmd,
+		ParamAction:  ActionAction,
+		ParamContent: content,
+		ParamURL:     URL,
+	})
+}
@@ -0,0 +1,130 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+func TestParseEvent_Location(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{})
+	payload := `{"_type":"location","lat":47.5,"lon":12.9,"alt":362,"acc":5,"vel":36,"tst":1618243873,"t":"u","batt":76}`
+
+	// when
+	event, err := parser.ParseEvent("owntracks/user/phone", []byte(payload))
+
+	// then: speed is converted from km/h to m/s
+	assert.NoError(t, err)
+	assert.Equal(t, "owntracks/user/phone", event.Topic)
+	assert.Equal(t, source.TypeLocation, event.Type)
+	assert.True(t, event.Manual)
+	assert.Equal(t, 47.5, event.Location.Latitude)
+	assert.Equal(t, 12.9, event.Location.Longitude)
+	assert.Equal(t, 362.0, *event.Location.Altitude)
+	assert.Equal(t, 5.0, *event.Location.Accuracy)
+	assert.Equal(t, 10.0, *event.Location.Speed)
+	assert.Equal(t, time.Unix(1618243873, 0), event.Location.Time)
+}
+
+func TestParseEvent_LocationWithoutCoordinates(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{})
+
+	// when
+	_, err := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"location","tst":1618243873}`))
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestParseEvent_Transition(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{})
+
+	// when
+	event, err := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"transition","event":"leave","desc":"Home"}`))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, source.TypeLeave, event.Type)
+	assert.Equal(t, "Home", event.Region)
+}
+
+func TestParseEvent_UnsupportedType(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{})
+
+	// when
+	_, err := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"lwt"}`))
+
+	// then
+	assert.IsType(t, &UnsupportedTypeError{}, err)
+}
+
+func TestParseEvent_Battery(t *testing.T) {
+	// given: charging with battery level, unplugged without, unknown battery status
+	parser := NewPayloadParser(Config{})
+
+	// when
+	charging, chargingErr := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"location","lat":1,"lon":2,"batt":76,"bs":2}`))
+	unplugged, unpluggedErr := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"location","lat":1,"lon":2,"bs":1}`))
+	unknown, unknownErr := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"location","lat":1,"lon":2,"bs":0}`))
+
+	// then
+	assert.NoError(t, chargingErr)
+	assert.Equal(t, 76.0, *charging.Location.Battery)
+	assert.True(t, *charging.Location.Charging)
+	assert.NoError(t, unpluggedErr)
+	assert.Nil(t, unplugged.Location.Battery)
+	assert.False(t, *unplugged.Location.Charging)
+	assert.NoError(t, unknownErr)
+	assert.Nil(t, unknown.Location.Charging)
+}
+
+func TestParseEvent_Mappings(t *testing.T) {
+	// given: a plain mapping for trackers and an extension of the OwnTracks mapping
+	parser := NewPayloadParser(Config{Mappings: []Mapping{
+		{Topic: "tracker/+", Fields: source.KeyMapping{
+			"latitude":  {Field: source.FieldLatitude},
+			"longitude": {Field: source.FieldLongitude},
+		}},
+		{Topic: "owntracks/user/+", Extend: true, Fields: source.KeyMapping{
+			"height": {Field: source.FieldAltitude},
+		}},
+	}})
+
+	// when
+	tracker, trackerErr := parser.ParseEvent("tracker/esp32", []byte(`{"latitude":47.5,"longitude":12.9}`))
+	extended, extendedErr := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"location","lat":47.5,"lon":12.9,"height":400}`))
+	other, otherErr := parser.ParseEvent("owntracks/other/phone", []byte(`{"_type":"location","lat":47.5,"lon":12.9,"height":400}`))
+
+	// then
+	assert.NoError(t, trackerErr)
+	assert.Equal(t, source.TypeLocation, tracker.Type)
+	assert.Equal(t, 47.5, tracker.Location.Latitude)
+	assert.NoError(t, extendedErr)
+	assert.Equal(t, 400.0, *extended.Location.Altitude)
+	assert.NoError(t, otherErr)
+	assert.Nil(t, other.Location.Altitude)
+}
+
+func TestParseEvent_MappingsExtendReplacesField(t *testing.T) {
+	// given: an extension mapping another key to the altitude
+	parser := NewPayloadParser(Config{Mappings: []Mapping{
+		{Topic: "owntracks/+/+", Extend: true, Fields: source.KeyMapping{
+			"height": {Field: source.FieldAltitude},
+		}},
+	}})
+
+	// when: both keys are in the payload
+	for i := 0; i < 20; i++ {
+		event, err := parser.ParseEvent("owntracks/user/phone", []byte(`{"_type":"location","lat":47.5,"lon":12.9,"alt":300,"height":400}`))
+
+		// then: the configured key always wins
+		assert.NoError(t, err)
+		assert.Equal(t, 400.0, *event.Location.Altitude)
+	}
+}
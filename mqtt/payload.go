@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tuffnerdstuff/hauk-snitch/source"
+)
+
+// PayloadParser unmarshals OwnTracks JSON payloads, decrypting them if necessary
+type PayloadParser struct {
+	encryptionKey  string
+	encryptionKeys []EncryptionKey
+	keyMapping     source.KeyMapping
+	mappings       []Mapping
+}
+
+// NewPayloadParser returns a parser using the encryption keys and mappings of the given config
+func NewPayloadParser(config Config) *PayloadParser {
+	parser := &PayloadParser{encryptionKey: config.EncryptionKey, encryptionKeys: config.EncryptionKeys, keyMapping: OwnTracksKeyMapping}
+	for _, mapping := range config.Mappings {
+		if mapping.Extend {
+			// configured keys replace the OwnTracks keys of the same field, otherwise map order decides which value wins
+			targets := make(map[string]bool)
+			fields := source.KeyMapping{}
+			for key, field := range mapping.Fields {
+				fields[key] = field
+				targets[field.Field] = true
+			}
+			for key, field := range OwnTracksKeyMapping {
+				if _, configured := fields[key]; !configured && !targets[field.Field] {
+					fields[key] = field
+				}
+			}
+			mapping.Fields = fields
+		}
+		parser.mappings = append(parser.mappings, mapping)
+	}
+	return parser
+}
+
+// Parse unmarshals the JSON payload of a message, decrypting it first if necessary
+func (t *PayloadParser) Parse(topic string, payload []byte) (map[string]interface{}, error) {
+	jsonMap := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &jsonMap); err != nil {
+		return nil, err
+	}
+	if jsonMap[ParamType] != TypeEncrypted {
+		return jsonMap, nil
+	}
+
+	key := t.encryptionKeyForTopic(topic)
+	if key == "" {
+		return nil, fmt.Errorf("Message is encrypted but no encryption key is configured")
+	}
+	data, _ := jsonMap[ParamData].(string)
+	decrypted, err := decryptPayload(data, key)
+	if err != nil {
+		return nil, err
+	}
+	decryptedMap := make(map[string]interface{})
+	if err := json.Unmarshal(decrypted, &decryptedMap); err != nil {
+		return nil, fmt.Errorf("Could not parse decrypted payload: %w", err)
+	}
+	return decryptedMap, nil
+}
+
+// mappingForTopic returns the first mapping matching the topic
+func (t *PayloadParser) mappingForTopic(topic string) (Mapping, bool) {
+	for _, mapping := range t.mappings {
+		if MatchTopic(mapping.Topic, topic) {
+			return mapping, true
+		}
+	}
+	return Mapping{}, false
+}
+
+func (t *PayloadParser) encryptionKeyForTopic(topic string) string {
+	for _, encryptionKey := range t.encryptionKeys {
+		if MatchTopic(encryptionKey.Topic, topic) {
+			return encryptionKey.Key
+		}
+	}
+	return t.encryptionKey
+}
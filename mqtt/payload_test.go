@@ -0,0 +1,74 @@
+package mqtt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+func TestParsePayload_Plain(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{})
+
+	// when
+	body, err := parser.Parse("owntracks/user/phone", []byte(`{"_type":"location","lat":47.5}`))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "location", body[ParamType])
+	assert.Equal(t, 47.5, body[ParamLatitude])
+}
+
+func TestParsePayload_Encrypted(t *testing.T) {
+	// given: topic specific key and global key
+	parser := NewPayloadParser(Config{
+		EncryptionKey:  "globalsecret",
+		EncryptionKeys: []EncryptionKey{{Topic: "owntracks/user/+", Key: "usersecret"}},
+	})
+
+	// when
+	userBody, userErr := parser.Parse("owntracks/user/phone", encrypt(t, "usersecret", `{"_type":"location","lat":47.5}`))
+	otherBody, otherErr := parser.Parse("owntracks/other/phone", encrypt(t, "globalsecret", `{"_type":"location","lat":12.9}`))
+
+	// then
+	assert.NoError(t, userErr)
+	assert.Equal(t, 47.5, userBody[ParamLatitude])
+	assert.NoError(t, otherErr)
+	assert.Equal(t, 12.9, otherBody[ParamLatitude])
+}
+
+func TestParsePayload_EncryptedWrongKey(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{EncryptionKey: "wrongsecret"})
+
+	// when
+	_, err := parser.Parse("owntracks/user/phone", encrypt(t, "secret", `{"_type":"location"}`))
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestParsePayload_EncryptedNoKey(t *testing.T) {
+	// given
+	parser := NewPayloadParser(Config{})
+
+	// when
+	_, err := parser.Parse("owntracks/user/phone", encrypt(t, "secret", `{"_type":"location"}`))
+
+	// then
+	assert.Error(t, err)
+}
+
+func encrypt(t *testing.T, secret string, plaintext string) []byte {
+	var key [keyLength]byte
+	copy(key[:], secret)
+	var nonce [nonceLength]byte
+	_, err := rand.Read(nonce[:])
+	assert.NoError(t, err)
+	encrypted := secretbox.Seal(nonce[:], []byte(plaintext), &nonce, &key)
+	return []byte(fmt.Sprintf(`{"_type":"encrypted","data":"%s"}`, base64.StdEncoding.EncodeToString(encrypted)))
+}
@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTLSConfig_Defaults(t *testing.T) {
+	// when
+	tlsConfig, err := newTLSConfig(Config{IsTLS: true})
+
+	// then: system CAs are used and certificates are verified
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.Empty(t, tlsConfig.Certificates)
+}
+
+func TestNewTLSConfig_InsecureAndServerName(t *testing.T) {
+	// when
+	tlsConfig, err := newTLSConfig(Config{IsTLS: true, IsInsecure: true, ServerName: "broker.lab"})
+
+	// then
+	assert.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+	assert.Equal(t, "broker.lab", tlsConfig.ServerName)
+}
+
+func TestNewTLSConfig_InvalidCAFile(t *testing.T) {
+	// given: CA file without certificates
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, []byte("no certificate"), 0600))
+
+	// when
+	_, err := newTLSConfig(Config{IsTLS: true, CAFile: caFile})
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestNewTLSConfig_MissingClientCertificate(t *testing.T) {
+	// when
+	_, err := newTLSConfig(Config{IsTLS: true, CertFile: "/does/not/exist.pem", KeyFile: "/does/not/exist.key"})
+
+	// then
+	assert.Error(t, err)
+}
@@ -0,0 +1,22 @@
+package mqtt
+
+import "strings"
+
+// MatchTopic returns true if the topic matches the given pattern.
+// The pattern may contain the MQTT wildcards "+" (single level) and "#" (multi level).
+func MatchTopic(pattern string, topic string) bool {
+	patternLevels := strings.Split(pattern, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, patternLevel := range patternLevels {
+		if patternLevel == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if patternLevel != "+" && patternLevel != topicLevels[i] {
+			return false
+		}
+	}
+	return len(patternLevels) == len(topicLevels)
+}
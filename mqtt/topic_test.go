@@ -0,0 +1,17 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchTopic(t *testing.T) {
+	assert.True(t, MatchTopic("owntracks/user/phone", "owntracks/user/phone"))
+	assert.True(t, MatchTopic("owntracks/+/phone", "owntracks/user/phone"))
+	assert.True(t, MatchTopic("owntracks/#", "owntracks/user/phone"))
+	assert.True(t, MatchTopic("#", "owntracks/user/phone"))
+	assert.False(t, MatchTopic("owntracks/+", "owntracks/user/phone"))
+	assert.False(t, MatchTopic("owntracks/user/phone/+", "owntracks/user/phone"))
+	assert.False(t, MatchTopic("owntracks/other/phone", "owntracks/user/phone"))
+}
@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message is a notification about a device
+type Message struct {
+	// Event is the type of the event the message is about, e.g. EventNewSession
+	Event  string
+	Device Device
+	// Title is a short summary of the message
+	Title string
+	// Text is the body of the message, channels supporting it render it as markdown
+	Text string
+	// HTML is the body of the message formatted as HTML, may be empty
+	HTML string
+	// URL is the session link the message is about, may be empty
+	URL string
+	// Recipient is the address, token, topic, chat or room the message is sent to, depending on the channel type.
+	// It is empty for channels which are not a RecipientChannel.
+	Recipient string
+}
+
+// Channel delivers notifications
+type Channel interface {
+	// Send delivers the message, giving up when the context is done
+	Send(ctx context.Context, message Message) error
+}
+
+// RecipientChannel is a Channel sending each message to one of several recipients
+type RecipientChannel interface {
+	Channel
+	// Recipients returns the configured recipients, used for devices without recipients of their own
+	Recipients() []string
+}
+
+// Factory creates a channel from its config block
+type Factory func(config ChannelConfig) (Channel, error)
+
+var factories = map[string]Factory{
+	ChannelSMTP:     newSMTPChannel,
+	ChannelGotify:   newGotifyChannel,
+	ChannelNtfy:     newNtfyChannel,
+	ChannelMatrix:   newMatrixChannel,
+	ChannelTelegram: newTelegramChannel,
+	ChannelWebhook:  newWebhookChannel,
+}
+
+// Register makes a channel type available for [notification.<name>] blocks.
+// It must be called before New.
+func Register(channelType string, factory Factory) {
+	factories[channelType] = factory
+}
+
+func newChannel(config ChannelConfig) (Channel, error) {
+	factory, exists := factories[config.Type]
+	if !exists {
+		return nil, fmt.Errorf("Unknown notification channel type %s in notification.%s", config.Type, config.Name)
+	}
+	channel, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create notification channel %s: %w", config.Name, err)
+	}
+	return channel, nil
+}
+
+// joinRecipients returns the single recipient followed by the list, omitting empty ones
+func joinRecipients(recipient string, recipients []string) []string {
+	var joined []string
+	for _, candidate := range append([]string{recipient}, recipients...) {
+		if candidate != "" {
+			joined = append(joined, candidate)
+		}
+	}
+	return joined
+}
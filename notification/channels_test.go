@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request is what the test server received
+type request struct {
+	method string
+	path   string
+	header http.Header
+	body   string
+}
+
+func startServer(t *testing.T, status int) (*httptest.Server, *request) {
+	received := &request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*received = request{method: r.Method, path: r.URL.EscapedPath(), header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func createMessage() Message {
+	return Message{
+		Event:  EventNewSession,
+		Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"},
+		Title:  "Forwarding Dude to Hauk",
+		Text:   "New session: URL",
+		URL:    "URL",
+	}
+}
+
+func TestNtfyChannel_Send(t *testing.T) {
+	// given
+	server, received := startServer(t, http.StatusOK)
+	channel, err := newNtfyChannel(ChannelConfig{Decode: decodeFrom(NtfyConfig{URL: server.URL, Topic: "family", Token: "token", Priority: 4})})
+	assert.NoError(t, err)
+	message := createMessage()
+	message.Recipient = "family"
+
+	// when
+	err = channel.Send(context.Background(), message)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "/family", received.path)
+	assert.Equal(t, "New session: URL", received.body)
+	assert.Equal(t, "Forwarding Dude to Hauk", received.header.Get("Title"))
+	assert.Equal(t, "URL", received.header.Get("Click"))
+	assert.Equal(t, "4", received.header.Get("Priority"))
+	assert.Equal(t, "Bearer token", received.header.Get("Authorization"))
+}
+
+func TestMatrixChannel_Send(t *testing.T) {
+	// given
+	server, received := startServer(t, http.StatusOK)
+	channel, err := newMatrixChannel(ChannelConfig{Decode: decodeFrom(MatrixConfig{Homeserver: server.URL, AccessToken: "token", RoomIDs: []string{"!room:example.com"}})})
+	assert.NoError(t, err)
+	message := createMessage()
+	message.Recipient = "!room:example.com"
+
+	// when
+	err = channel.Send(context.Background(), message)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, received.method)
+	assert.Regexp(t, "^/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/.+$", received.path)
+	assert.Equal(t, "Bearer token", received.header.Get("Authorization"))
+	assert.JSONEq(t, `{"msgtype": "m.text", "body": "Forwarding Dude to Hauk\n\nNew session: URL"}`, received.body)
+}
+
+func TestTelegramChannel_Send(t *testing.T) {
+	// given
+	server, received := startServer(t, http.StatusOK)
+	channel, err := newTelegramChannel(ChannelConfig{Decode: decodeFrom(TelegramConfig{URL: server.URL, Token: "123:abc", ChatID: "42"})})
+	assert.NoError(t, err)
+	message := createMessage()
+	message.Recipient = "42"
+
+	// when
+	err = channel.Send(context.Background(), message)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "/bot123:abc/sendMessage", received.path)
+	assert.JSONEq(t, `{"chat_id": "42", "text": "Forwarding Dude to Hauk\n\nNew session: URL"}`, received.body)
+}
+
+func TestWebhookChannel_Send(t *testing.T) {
+	// given
+	server, received := startServer(t, http.StatusOK)
+	channel, err := newWebhookChannel(ChannelConfig{Decode: decodeFrom(WebhookConfig{URL: server.URL + "/hook", Method: "PUT", Headers: map[string]string{"authorization": "Bearer token"}})})
+	assert.NoError(t, err)
+
+	// when
+	err = channel.Send(context.Background(), createMessage())
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, received.method)
+	assert.Equal(t, "/hook", received.path)
+	assert.Equal(t, "Bearer token", received.header.Get("Authorization"))
+	var payload map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(received.body), &payload))
+	assert.Equal(t, map[string]string{
+		"event": "new_session",
+		"topic": "owntracks/dude/phone",
+		"name":  "Dude",
+		"title": "Forwarding Dude to Hauk",
+		"text":  "New session: URL",
+		"url":   "URL",
+	}, payload)
+}
+
+func TestWebhookChannel_SendFailed(t *testing.T) {
+	// given
+	server, _ := startServer(t, http.StatusInternalServerError)
+	channel, err := newWebhookChannel(ChannelConfig{Decode: decodeFrom(WebhookConfig{URL: server.URL})})
+	assert.NoError(t, err)
+
+	// when
+	err = channel.Send(context.Background(), createMessage())
+
+	// then
+	var httpError *HTTPError
+	assert.ErrorAs(t, err, &httpError)
+	assert.Equal(t, http.StatusInternalServerError, httpError.Status)
+}
+
+func TestMatrixChannel_SendHTML(t *testing.T) {
+	// given
+	server, received := startServer(t, http.StatusOK)
+	channel, err := newMatrixChannel(ChannelConfig{Decode: decodeFrom(MatrixConfig{Homeserver: server.URL, AccessToken: "token", RoomIDs: []string{"!room:example.com"}})})
+	assert.NoError(t, err)
+	message := createMessage()
+	message.Recipient = "!room:example.com"
+	message.HTML = "<a href=\"URL\">Dude</a>"
+
+	// when
+	err = channel.Send(context.Background(), message)
+
+	// then
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"msgtype": "m.text",
+		"body": "Forwarding Dude to Hauk\n\nNew session: URL",
+		"format": "org.matrix.custom.html",
+		"formatted_body": "<a href=\"URL\">Dude</a>"
+	}`, received.body)
+}
+
+func TestTelegramChannel_SendUnreachable(t *testing.T) {
+	// given: server which is not running anymore
+	server, _ := startServer(t, http.StatusOK)
+	server.Close()
+	channel, err := newTelegramChannel(ChannelConfig{Decode: decodeFrom(TelegramConfig{URL: server.URL, Token: "123:secret"})})
+	assert.NoError(t, err)
+	message := createMessage()
+	message.Recipient = "42"
+
+	// when
+	err = channel.Send(context.Background(), message)
+
+	// then: token is not part of the error
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "123:secret")
+	assert.Contains(t, err.Error(), "/bot<token>/sendMessage")
+}
@@ -1,24 +1,56 @@
 package notification
 
-// Config holds the configuration for the eMail notification about new Hauk sessions
+import "time"
+
+// Config holds the configuration of the notification channels
 type Config struct {
-	Smtp   SMTPConfig
-	Gotify GotifyConfig
+	Channels []ChannelConfig
 }
 
-type SMTPConfig struct {
+// ChannelConfig is the [notification.<name>] block of a channel
+type ChannelConfig struct {
+	// Name of the block, used to select the channel per device
+	Name string
+	// Type of the channel, one of the registered channel types
+	Type    string
 	Enabled bool
-	Host    string
-	Port    int
-	From    string
-	To      string
-	Login    string
-	Password string
+	// Decode decodes the settings of the block into the config struct of the channel type
+	Decode func(target interface{}) error
+	// Events the channel is subscribed to, nil means all
+	Events []string
+	// Templates of the messages by event type, e.g. EventNewSession
+	Templates map[string]TemplateConfig
+	Delivery  DeliveryConfig
+}
+
+// DeliveryConfig holds how messages are delivered to a channel
+type DeliveryConfig struct {
+	// Timeout of a single attempt, unset values are replaced by defaults
+	Timeout time.Duration
+	// Retries after the first failed attempt, with exponential backoff between RetryInitial and RetryMax
+	Retries      int
+	RetryInitial time.Duration
+	RetryMax     time.Duration
+	// QueueSize is the number of messages waiting for delivery, further ones are given up
+	QueueSize int
 }
 
-type GotifyConfig struct {
-	Enabled  bool
-	URL      string
-	AppToken string
-	Priority int
+// withDefaults returns the config with unset values replaced by their defaults
+func (t DeliveryConfig) withDefaults() DeliveryConfig {
+	if t.Timeout <= 0 {
+		t.Timeout = defaultTimeout
+	}
+	if t.RetryInitial <= 0 {
+		t.RetryInitial = defaultRetryInitial
+	}
+	if t.RetryMax <= 0 {
+		t.RetryMax = defaultRetryMax
+	}
+	if t.RetryMax < t.RetryInitial {
+		t.RetryMax = t.RetryInitial
+	}
+	if t.QueueSize <= 0 {
+		t.QueueSize = defaultQueueSize
+	}
+	return t
 }
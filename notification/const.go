@@ -0,0 +1,48 @@
+package notification
+
+import "time"
+
+// ChannelGotify is the type of the Gotify notification channel
+const ChannelGotify string = "gotify"
+
+// ChannelSMTP is the type of the eMail notification channel
+const ChannelSMTP string = "smtp"
+
+// ChannelNtfy is the type of the ntfy notification channel
+const ChannelNtfy string = "ntfy"
+
+// ChannelMatrix is the type of the Matrix notification channel
+const ChannelMatrix string = "matrix"
+
+// ChannelTelegram is the type of the Telegram notification channel
+const ChannelTelegram string = "telegram"
+
+// ChannelWebhook is the type of the generic JSON webhook notification channel
+const ChannelWebhook string = "webhook"
+
+// EventNewSession means a session has been created
+const EventNewSession string = "new_session"
+
+// EventSessionStopped means a session has been stopped
+const EventSessionStopped string = "session_stopped"
+
+// EventSessionExpired means Hauk reported the session as expired
+const EventSessionExpired string = "session_expired"
+
+// EventSessionFailed means a session could not be created
+const EventSessionFailed string = "session_failed"
+
+// EventDeviceSilent means no location of a device with a session has been received for a while
+const EventDeviceSilent string = "device_silent"
+
+// defaultTimeout is the default timeout of a single delivery attempt
+const defaultTimeout = 30 * time.Second
+
+// defaultRetryInitial is the default wait before retrying a failed delivery
+const defaultRetryInitial = 5 * time.Second
+
+// defaultRetryMax is the default maximum wait between retries
+const defaultRetryMax = 5 * time.Minute
+
+// defaultQueueSize is the default number of messages waiting for delivery per channel
+const defaultQueueSize = 100
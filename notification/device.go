@@ -0,0 +1,47 @@
+package notification
+
+import "strings"
+
+// Device describes the device a notification is about and who is notified
+type Device struct {
+	Topic string
+	// Name is shown instead of the topic if not empty
+	Name string
+	// Channels limits notifications to the channels with the given names, nil means all enabled channels
+	Channels []string
+	// EmailTo overrides the recipients of all eMail channels if not nil
+	EmailTo []string
+	// Recipients override the recipients of the channels with the given lowercase names, an empty list disables the channel
+	Recipients map[string][]string
+}
+
+// DisplayName returns the name of the device, or its topic if it has none
+func (t Device) DisplayName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Topic
+}
+
+func (t Device) wantsChannel(channel string) bool {
+	if t.Channels == nil {
+		return true
+	}
+	for _, wantedChannel := range t.Channels {
+		if strings.EqualFold(wantedChannel, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// recipientsFor returns the recipients of the device for the channel, nil means the recipients of the channel
+func (t Device) recipientsFor(channel string, channelType string) []string {
+	if recipients, exists := t.Recipients[strings.ToLower(channel)]; exists {
+		return recipients
+	}
+	if channelType == ChannelSMTP {
+		return t.EmailTo
+	}
+	return nil
+}
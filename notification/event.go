@@ -0,0 +1,28 @@
+package notification
+
+import "time"
+
+// Event is something that happened to the session of a device
+type Event struct {
+	// Type is one of the Event* constants
+	Type   string
+	Device Device
+	// Session is the session the event is about, empty if there is none
+	Session Session
+	// Err is the reason for EventSessionFailed
+	Err error
+	// LastSeen is the time of the last location for EventDeviceSilent
+	LastSeen time.Time
+}
+
+// eventTypes are all event types, in the order they are documented
+var eventTypes = []string{EventNewSession, EventSessionStopped, EventSessionExpired, EventSessionFailed, EventDeviceSilent}
+
+func isEventType(eventType string) bool {
+	for _, known := range eventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
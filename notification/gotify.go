@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gotify/go-api-client/v2/auth"
+	"github.com/gotify/go-api-client/v2/client"
+	"github.com/gotify/go-api-client/v2/client/message"
+	"github.com/gotify/go-api-client/v2/gotify"
+	"github.com/gotify/go-api-client/v2/models"
+)
+
+// GotifyConfig holds the configuration of a Gotify channel
+type GotifyConfig struct {
+	URL      string `mapstructure:"url"`
+	AppToken string `mapstructure:"app_token"`
+	// AppTokens are further applications, each one is sent the message
+	AppTokens []string `mapstructure:"app_tokens"`
+	Priority  int      `mapstructure:"priority"`
+}
+
+type gotifyChannel struct {
+	config GotifyConfig
+	client *client.GotifyREST
+}
+
+func newGotifyChannel(channelConfig ChannelConfig) (Channel, error) {
+	config := GotifyConfig{Priority: 5}
+	if err := channelConfig.Decode(&config); err != nil {
+		return nil, err
+	}
+	gotifyURL, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Gotify URL %s: %w", config.URL, err)
+	}
+	return &gotifyChannel{config: config, client: gotify.NewClient(gotifyURL, &http.Client{})}, nil
+}
+
+// Recipients returns the configured app tokens
+func (t *gotifyChannel) Recipients() []string {
+	return joinRecipients(t.config.AppToken, t.config.AppTokens)
+}
+
+// Send pushes the markdown message to the application of the recipient token, clicking the notification opens the session link
+func (t *gotifyChannel) Send(ctx context.Context, notification Message) error {
+	params := message.NewCreateMessageParamsWithContext(ctx)
+
+	extras := map[string]interface{}{
+		"client::display": map[string]interface{}{
+			"contentType": "text/markdown",
+		},
+	}
+	if notification.URL != "" {
+		extras["client::notification"] = map[string]interface{}{
+			"click": map[string]interface{}{"url": notification.URL},
+		}
+	}
+
+	params.Body = &models.MessageExternal{
+		Title:    notification.Title,
+		Message:  notification.Text,
+		Priority: t.config.Priority,
+		Extras:   extras,
+	}
+	_, err := t.client.Message.CreateMessage(params, auth.TokenAuth(notification.Recipient))
+	return err
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var httpClient = &http.Client{}
+
+// HTTPError is returned if a channel responds with a status other than 2xx
+type HTTPError struct {
+	Status int
+	Body   string
+}
+
+func (t *HTTPError) Error() string {
+	return fmt.Sprintf("Unexpected response %d: %s", t.Status, t.Body)
+}
+
+// newJSONRequest creates a request with the payload encoded as JSON
+func newJSONRequest(ctx context.Context, method string, url string, payload interface{}) (*http.Request, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Could not encode payload: %w", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return request, nil
+}
+
+// do sends the request and returns an HTTPError unless the response status is 2xx
+func do(request *http.Request) error {
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return &HTTPError{Status: response.StatusCode, Body: string(body)}
+	}
+	return nil
+}
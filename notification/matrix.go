@@ -0,0 +1,67 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MatrixConfig holds the configuration of a Matrix channel posting to a room
+type MatrixConfig struct {
+	Homeserver  string `mapstructure:"homeserver"`
+	AccessToken string `mapstructure:"access_token"`
+	RoomID      string `mapstructure:"room_id"`
+	// RoomIDs are further rooms, each one is sent the message
+	RoomIDs []string `mapstructure:"room_ids"`
+}
+
+type matrixChannel struct {
+	config MatrixConfig
+	// transactions makes transaction IDs unique within this process
+	transactions uint64
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+func newMatrixChannel(channelConfig ChannelConfig) (Channel, error) {
+	var config MatrixConfig
+	if err := channelConfig.Decode(&config); err != nil {
+		return nil, err
+	}
+	if config.Homeserver == "" || config.AccessToken == "" {
+		return nil, fmt.Errorf("Matrix homeserver and access_token must be set")
+	}
+	return &matrixChannel{config: config}, nil
+}
+
+// Recipients returns the configured room IDs
+func (t *matrixChannel) Recipients() []string {
+	return joinRecipients(t.config.RoomID, t.config.RoomIDs)
+}
+
+// Send posts the message to the recipient room using the client-server API
+func (t *matrixChannel) Send(ctx context.Context, message Message) error {
+	transactionID := fmt.Sprintf("hauksnitch%d.%d", time.Now().UnixNano(), atomic.AddUint64(&t.transactions, 1))
+	sendURL := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(t.config.Homeserver, "/"), url.PathEscape(message.Recipient), transactionID)
+	matrixMessage := matrixMessage{MsgType: "m.text", Body: message.Title + "\n\n" + message.Text}
+	if message.HTML != "" {
+		matrixMessage.Format = "org.matrix.custom.html"
+		matrixMessage.FormattedBody = message.HTML
+	}
+	request, err := newJSONRequest(ctx, http.MethodPut, sendURL, matrixMessage)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+t.config.AccessToken)
+	return do(request)
+}
@@ -3,71 +3,88 @@ package notification
 import (
 	"fmt"
 	"log"
-	"net/smtp"
 
-	"net/http"
-	"net/url"
-
-	"github.com/gotify/go-api-client/v2/auth"
-	"github.com/gotify/go-api-client/v2/client/message"
-	"github.com/gotify/go-api-client/v2/gotify"
-	"github.com/gotify/go-api-client/v2/models"
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
 )
 
-// Notifier can send email notifications about events in the mapper
+// Notifier can send notifications about events in the mapper
 type Notifier interface {
-	NotifyNewSession(topic string, URL string)
-}
-
-type notifier struct {
-	config Config
+	Notify(event Event)
 }
 
-// New returns a new Notifier instance
-func New(config Config) Notifier {
-	return &notifier{config: config}
+// Dispatcher is a Notifier delivering messages in the background.
+// Each channel has its own queue, so a slow channel does not delay the others.
+type Dispatcher struct {
+	workers []*channelWorker
 }
 
-func (t *notifier) NotifyNewSession(topic string, URL string) {
-	if t.config.Gotify.Enabled {
-		myURL, _ := url.Parse(t.config.Gotify.URL)
-		client := gotify.NewClient(myURL, &http.Client{})
-
-		params := message.NewCreateMessageParams()
-
-		extras := map[string]interface{}{
-			"client::display": map[string]interface{}{
-				"contentType": "text/markdown",
-			},
-			"client::notification": map[string]interface{}{
-				"click": map[string]interface{}{"url": URL},
-			},
+// New returns a new Dispatcher sending to all enabled channels
+func New(config Config) (*Dispatcher, error) {
+	dispatcher := &Dispatcher{}
+	for _, channelConfig := range config.Channels {
+		if !channelConfig.Enabled {
+			continue
 		}
-
-		params.Body = &models.MessageExternal{
-			Title:    "Hauk-Snitch",
-			Message:  fmt.Sprintf("Forwarding **%s** to Hauk\r\n\r\nNew session: [hauk link](%s)", topic, URL),
-			Priority: t.config.Gotify.Priority,
-			Extras:   extras,
+		for _, eventType := range channelConfig.Events {
+			if !isEventType(eventType) {
+				return nil, fmt.Errorf("Unknown event type %s in notification.%s", eventType, channelConfig.Name)
+			}
 		}
-		_, err := client.Message.CreateMessage(params, auth.TokenAuth(t.config.Gotify.AppToken))
-
+		channel, err := newChannel(channelConfig)
+		if err != nil {
+			return nil, err
+		}
+		if recipientChannel, ok := channel.(RecipientChannel); ok && len(recipientChannel.Recipients()) == 0 {
+			log.Printf("Notification %s has no recipients, only devices with recipients of their own are notified", channelConfig.Name)
+		}
+		templates, err := newTemplates(channelConfig.Templates, markdownChannels[channelConfig.Type])
 		if err != nil {
-			log.Fatalf("Gotify: could not send message %v", err)
-			return
+			return nil, fmt.Errorf("Invalid templates of notification channel %s: %w", channelConfig.Name, err)
 		}
-		log.Println("Gotify: message Sent!")
+		dispatcher.workers = append(dispatcher.workers, newChannelWorker(channelConfig, channel, templates))
 	}
+	return dispatcher, nil
+}
 
-	if t.config.Smtp.Enabled {
-		host := fmt.Sprintf("%s:%d", t.config.Smtp.Host, t.config.Smtp.Port)
-		var auth smtp.Auth
-	        if t.config.Smtp.Login != "" {
-                       auth = smtp.PlainAuth("", t.config.Smtp.Login, t.config.Smtp.Password, t.config.Smtp.Host)
+// Notify queues a message about the event for all channels subscribed to its type.
+// It does not wait for the messages to be delivered.
+func (t *Dispatcher) Notify(event Event) {
+	data := newTemplateData(event)
+	for _, worker := range t.workers {
+		if !event.Device.wantsChannel(worker.name) || !worker.isSubscribed(event.Type) {
+			continue
 		}
-		err := smtp.SendMail(host, auth, t.config.Smtp.From, []string{t.config.Smtp.To}, []byte(fmt.Sprintf("Subject: Forwarding %s to Hauk\r\n\r\nNew session: %s", topic, URL)))
+		message, err := worker.templates[event.Type].render(data)
 		if err != nil {
-			log.Printf("Smtp: could not send email notification: %v", err)
+			metrics.NotificationsSent.WithLabelValues(worker.name, metrics.ResultFailure).Inc()
+			log.Printf("Notification %s: could not render %s message: %v", worker.name, event.Type, err)
+			continue
+		}
+		message.Event = event.Type
+		message.Device = event.Device
+		message.URL = event.Session.URL
+		recipients, hasRecipients, err := worker.recipients(event.Device)
+		if err != nil {
+			metrics.NotificationsSent.WithLabelValues(worker.name, metrics.ResultFailure).Inc()
+			log.Printf("Notification %s: could not send %s message: %v", worker.name, event.Type, err)
+			continue
+		}
+		if !hasRecipients {
+			worker.enqueue(delivery{eventType: event.Type, message: message})
+			continue
 		}
+		// Every recipient gets a message of their own, so a failing one does not cause duplicates for the others
+		for _, recipient := range recipients {
+			message.Recipient = recipient
+			worker.enqueue(delivery{eventType: event.Type, message: message})
+		}
+	}
+}
+
+// Close waits until all queued messages have been delivered or given up.
+// Messages waiting for a retry are given up immediately.
+func (t *Dispatcher) Close() {
+	for _, worker := range t.workers {
+		worker.close()
 	}
 }
@@ -0,0 +1,227 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeFrom returns a Decode function copying the given channel config into the target
+func decodeFrom(config interface{}) func(target interface{}) error {
+	return func(target interface{}) error {
+		reflect.ValueOf(target).Elem().Set(reflect.ValueOf(config))
+		return nil
+	}
+}
+
+type recordingChannel struct {
+	mutex    sync.Mutex
+	messages []Message
+	err      error
+	// failures is the number of attempts failing before err is returned
+	failures int
+}
+
+func (t *recordingChannel) Send(ctx context.Context, message Message) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.messages = append(t.messages, message)
+	if t.failures > 0 {
+		t.failures--
+		return errors.New("temporary")
+	}
+	return t.err
+}
+
+func TestNew_UnknownType(t *testing.T) {
+	// when
+	_, err := New(Config{Channels: []ChannelConfig{{Name: "pigeon", Type: "pigeon", Enabled: true}}})
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestNew_InvalidChannel(t *testing.T) {
+	// when: matrix without homeserver
+	_, err := New(Config{Channels: []ChannelConfig{{Name: "matrix", Type: ChannelMatrix, Enabled: true, Decode: decodeFrom(MatrixConfig{AccessToken: "token"})}}})
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestNotify(t *testing.T) {
+	// given: registered channel type with two instances and a disabled one
+	team := &recordingChannel{}
+	family := &recordingChannel{err: errors.New("nope")}
+	disabled := &recordingChannel{}
+	channels := map[string]Channel{"team": team, "family": family, "disabled": disabled}
+	Register("recording", func(config ChannelConfig) (Channel, error) {
+		return channels[config.Name], nil
+	})
+	notifier, err := New(Config{Channels: []ChannelConfig{
+		{Name: "team", Type: "recording", Enabled: true},
+		{Name: "family", Type: "recording", Enabled: true, Events: []string{EventNewSession, EventSessionFailed}},
+		{Name: "disabled", Type: "recording"},
+	}})
+	assert.NoError(t, err)
+
+	// when: device wants all channels, then only the team channel
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}, Session: Session{URL: "URL"}})
+	notifier.Notify(Event{Type: EventSessionStopped, Device: Device{Topic: "owntracks/dude/phone", Channels: []string{"Team"}}})
+	notifier.Notify(Event{Type: EventSessionFailed, Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}, Err: errors.New("Hauk down")})
+	notifier.Notify(Event{Type: EventSessionExpired, Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}})
+	notifier.Close()
+
+	// then: failing channels do not stop the others, family only gets subscribed events
+	assert.Len(t, team.messages, 4)
+	assert.Equal(t, EventNewSession, team.messages[0].Event)
+	assert.Equal(t, "Forwarding Dude to Hauk", team.messages[0].Title)
+	assert.Equal(t, "URL", team.messages[0].URL)
+	assert.Equal(t, "Creating a session failed: Hauk down", team.messages[2].Text)
+	assert.Equal(t, "Session of Dude expired", team.messages[3].Title)
+	assert.Len(t, family.messages, 2)
+	assert.Empty(t, disabled.messages)
+}
+
+func TestNew_UnknownEventType(t *testing.T) {
+	// when
+	_, err := New(Config{Channels: []ChannelConfig{{Name: "ntfy", Type: ChannelNtfy, Enabled: true, Events: []string{"birthday"}}}})
+
+	// then
+	assert.Error(t, err)
+}
+
+// recipientChannel records the messages of a channel with recipients
+type recipientChannel struct {
+	recordingChannel
+	recipients []string
+}
+
+func (t *recipientChannel) Recipients() []string {
+	return t.recipients
+}
+
+func TestNotify_Recipients(t *testing.T) {
+	// given: channel with recipients and one without
+	family := &recipientChannel{recipients: []string{"mom", "dad"}}
+	webhook := &recordingChannel{}
+	channels := map[string]Channel{"family": family, "webhook": webhook}
+	Register("recipients", func(config ChannelConfig) (Channel, error) {
+		return channels[config.Name], nil
+	})
+	notifier, err := New(Config{Channels: []ChannelConfig{
+		{Name: "family", Type: "recipients", Enabled: true},
+		{Name: "webhook", Type: "recipients", Enabled: true},
+	}})
+	assert.NoError(t, err)
+
+	// when: device with the configured recipients, with its own and with none
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/dude/phone"}})
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/kid/phone", Recipients: map[string][]string{"family": {"grandma"}}}})
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/cat/collar", Recipients: map[string][]string{"family": {}}}})
+	notifier.Close()
+
+	// then: every recipient gets a message of its own, channels without recipients get one message per event
+	var recipients []string
+	for _, message := range family.messages {
+		recipients = append(recipients, message.Recipient)
+	}
+	assert.ElementsMatch(t, []string{"mom", "dad", "grandma"}, recipients)
+	assert.Len(t, webhook.messages, 3)
+}
+
+func TestNotify_NoRecipients(t *testing.T) {
+	// given: channel without recipients
+	channel := &recipientChannel{}
+	Register("norecipients", func(config ChannelConfig) (Channel, error) {
+		return channel, nil
+	})
+	notifier, err := New(Config{Channels: []ChannelConfig{{Name: "nobody", Type: "norecipients", Enabled: true}}})
+	assert.NoError(t, err)
+	worker := notifier.workers[0]
+
+	// when
+	_, _, noRecipientsErr := worker.recipients(Device{Topic: "owntracks/dude/phone"})
+	recipients, _, deviceErr := worker.recipients(Device{Topic: "owntracks/kid/phone", Recipients: map[string][]string{"nobody": {"grandma"}}})
+	notifier.Close()
+
+	// then: only devices with recipients of their own can be notified
+	assert.Error(t, noRecipientsErr)
+	assert.NoError(t, deviceErr)
+	assert.Equal(t, []string{"grandma"}, recipients)
+}
+
+func TestDevice_RecipientsFor(t *testing.T) {
+	// given
+	device := Device{EmailTo: []string{"mom@example.com"}, Recipients: map[string][]string{"school": {"school@example.com"}}}
+
+	// then: recipients by name win over EmailTo, which applies to eMail channels only
+	assert.Equal(t, []string{"school@example.com"}, device.recipientsFor("School", ChannelSMTP))
+	assert.Equal(t, []string{"mom@example.com"}, device.recipientsFor("smtp", ChannelSMTP))
+	assert.Nil(t, device.recipientsFor("family", ChannelNtfy))
+}
+
+func TestNotify_Retry(t *testing.T) {
+	// given: channel failing twice, allowing two retries
+	channel := &recordingChannel{failures: 2}
+	Register("retrying", func(config ChannelConfig) (Channel, error) {
+		return channel, nil
+	})
+	notifier, err := New(Config{Channels: []ChannelConfig{
+		{Name: "retrying", Type: "retrying", Enabled: true, Delivery: DeliveryConfig{Retries: 2, RetryInitial: time.Millisecond}},
+	}})
+	assert.NoError(t, err)
+
+	// when
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}})
+
+	// then: delivered with the third attempt
+	assert.Eventually(t, func() bool {
+		channel.mutex.Lock()
+		defer channel.mutex.Unlock()
+		return len(channel.messages) == 3
+	}, time.Second, time.Millisecond)
+	notifier.Close()
+	assert.Len(t, channel.messages, 3)
+}
+
+func TestNotify_DeadLetter(t *testing.T) {
+	// given: channel failing with a retry far in the future
+	channel := &recordingChannel{err: errors.New("nope")}
+	Register("failing", func(config ChannelConfig) (Channel, error) {
+		return channel, nil
+	})
+	notifier, err := New(Config{Channels: []ChannelConfig{
+		{Name: "failing", Type: "failing", Enabled: true, Delivery: DeliveryConfig{Retries: 3, RetryInitial: time.Hour}},
+	}})
+	assert.NoError(t, err)
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}})
+	assert.Eventually(t, func() bool {
+		channel.mutex.Lock()
+		defer channel.mutex.Unlock()
+		return len(channel.messages) == 1
+	}, time.Second, time.Millisecond)
+
+	// when
+	notifier.Close()
+	notifier.Notify(Event{Type: EventNewSession, Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}})
+
+	// then: pending retry is given up and closed notifier does not send
+	assert.Len(t, channel.messages, 1)
+}
+
+func TestChannelWorker_Backoff(t *testing.T) {
+	// given
+	worker := &channelWorker{delivery: DeliveryConfig{RetryInitial: time.Second, RetryMax: 5 * time.Second}}
+
+	// then: doubling up to the maximum
+	assert.Equal(t, time.Second, worker.backoff(1))
+	assert.Equal(t, 2*time.Second, worker.backoff(2))
+	assert.Equal(t, 4*time.Second, worker.backoff(3))
+	assert.Equal(t, 5*time.Second, worker.backoff(4))
+}
@@ -0,0 +1,60 @@
+package notification
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NtfyConfig holds the configuration of an ntfy channel
+type NtfyConfig struct {
+	URL   string `mapstructure:"url"`
+	Topic string `mapstructure:"topic"`
+	// Topics are further topics, each one is sent the message
+	Topics []string `mapstructure:"topics"`
+	// Token is an access token for protected topics, may be empty
+	Token    string `mapstructure:"token"`
+	Priority int    `mapstructure:"priority"`
+}
+
+type ntfyChannel struct {
+	config NtfyConfig
+}
+
+func newNtfyChannel(channelConfig ChannelConfig) (Channel, error) {
+	var config NtfyConfig
+	if err := channelConfig.Decode(&config); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		config.URL = "https://ntfy.sh"
+	}
+	return &ntfyChannel{config: config}, nil
+}
+
+// Recipients returns the configured topics
+func (t *ntfyChannel) Recipients() []string {
+	return joinRecipients(t.config.Topic, t.config.Topics)
+}
+
+// Send publishes the message as markdown to the recipient topic, clicking the notification opens the session link
+func (t *ntfyChannel) Send(ctx context.Context, message Message) error {
+	url := strings.TrimSuffix(t.config.URL, "/") + "/" + message.Recipient
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(message.Text))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Title", message.Title)
+	request.Header.Set("Markdown", "yes")
+	if message.URL != "" {
+		request.Header.Set("Click", message.URL)
+	}
+	if t.config.Priority != 0 {
+		request.Header.Set("Priority", strconv.Itoa(t.config.Priority))
+	}
+	if t.config.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+t.config.Token)
+	}
+	return do(request)
+}
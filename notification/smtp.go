@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+)
+
+// SMTPConfig holds the configuration of an eMail channel
+type SMTPConfig struct {
+	Host     string `mapstructure:"smtp_host"`
+	Port     int    `mapstructure:"smtp_port"`
+	Login    string `mapstructure:"smtp_login"`
+	Password string `mapstructure:"smtp_password"`
+	From     string `mapstructure:"from"`
+	// To are the recipients, a single address is accepted as well
+	To []string `mapstructure:"to"`
+}
+
+type smtpChannel struct {
+	config SMTPConfig
+}
+
+func newSMTPChannel(channelConfig ChannelConfig) (Channel, error) {
+	config := SMTPConfig{Host: "localhost", Port: 25, From: "noreply@hauk-snitch.local"}
+	if err := channelConfig.Decode(&config); err != nil {
+		return nil, err
+	}
+	return &smtpChannel{config: config}, nil
+}
+
+// Recipients returns the configured eMail addresses
+func (t *smtpChannel) Recipients() []string {
+	return t.config.To
+}
+
+// Send mails the message to the recipient, so recipients do not see each other
+func (t *smtpChannel) Send(ctx context.Context, message Message) error {
+	mail := fmt.Sprintf("To: %s\r\nSubject: %s\r\n%s", message.Recipient, mime.QEncoding.Encode("utf-8", message.Title), mailBody(message))
+	return t.sendMail(ctx, message.Recipient, []byte(mail))
+}
+
+// sendMail works like smtp.SendMail, but gives up when the context is done
+func (t *smtpChannel) sendMail(ctx context.Context, to string, mail []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", t.config.Host, t.config.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, t.config.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if supported, _ := client.Extension("STARTTLS"); supported {
+		if err = client.StartTLS(&tls.Config{ServerName: t.config.Host}); err != nil {
+			return err
+		}
+	}
+	if t.config.Login != "" {
+		if err = client.Auth(smtp.PlainAuth("", t.config.Login, t.config.Password, t.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(t.config.From); err != nil {
+		return err
+	}
+	if err = client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(mail); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// mailBody returns the MIME headers and body of the mail, multipart if there is an HTML body
+func mailBody(message Message) string {
+	if message.HTML == "" {
+		return fmt.Sprintf("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s", message.Text)
+	}
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		partWriter, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		partWriter.Write([]byte(part.content))
+	}
+	writer.Close()
+	return fmt.Sprintf("MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n%s", writer.Boundary(), buffer.String())
+}
@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TelegramConfig holds the configuration of a Telegram channel sending as bot to a chat
+type TelegramConfig struct {
+	URL    string `mapstructure:"url"`
+	Token  string `mapstructure:"token"`
+	ChatID string `mapstructure:"chat_id"`
+	// ChatIDs are further chats, each one is sent the message
+	ChatIDs []string `mapstructure:"chat_ids"`
+}
+
+type telegramChannel struct {
+	config TelegramConfig
+}
+
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+func newTelegramChannel(channelConfig ChannelConfig) (Channel, error) {
+	var config TelegramConfig
+	if err := channelConfig.Decode(&config); err != nil {
+		return nil, err
+	}
+	if config.URL == "" {
+		config.URL = "https://api.telegram.org"
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("Telegram token must be set")
+	}
+	return &telegramChannel{config: config}, nil
+}
+
+// Recipients returns the configured chat IDs
+func (t *telegramChannel) Recipients() []string {
+	return joinRecipients(t.config.ChatID, t.config.ChatIDs)
+}
+
+// Send sends the message to the recipient chat using the Bot API.
+// The HTML body is preferred, it may only use the tags Telegram supports.
+func (t *telegramChannel) Send(ctx context.Context, message Message) error {
+	sendURL := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(t.config.URL, "/"), t.config.Token)
+	telegramMessage := telegramMessage{ChatID: message.Recipient, Text: message.Title + "\n\n" + message.Text}
+	if message.HTML != "" {
+		telegramMessage.Text = message.HTML
+		telegramMessage.ParseMode = "HTML"
+	}
+	request, err := newJSONRequest(ctx, http.MethodPost, sendURL, telegramMessage)
+	if err != nil {
+		return t.withoutToken(err)
+	}
+	return t.withoutToken(do(request))
+}
+
+// withoutToken removes the bot token from the URL of the error, so it does not end up in the logs
+func (t *telegramChannel) withoutToken(err error) error {
+	var urlError *url.Error
+	if !errors.As(err, &urlError) {
+		return err
+	}
+	redacted := *urlError
+	redacted.URL = strings.ReplaceAll(redacted.URL, t.config.Token, "<token>")
+	return &redacted
+}
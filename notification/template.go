@@ -0,0 +1,208 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"text/template"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+)
+
+// TemplateConfig holds the templates of the message about one event type.
+// A file takes precedence over the template given inline, empty templates fall back to the default.
+type TemplateConfig struct {
+	Subject      string `mapstructure:"subject"`
+	SubjectFile  string `mapstructure:"subject_file"`
+	Body         string `mapstructure:"body"`
+	BodyFile     string `mapstructure:"body_file"`
+	HTMLBody     string `mapstructure:"html_body"`
+	HTMLBodyFile string `mapstructure:"html_body_file"`
+}
+
+// TemplateData is available in templates
+type TemplateData struct {
+	// Name is the display name of the device
+	Name  string
+	Topic string
+	// URL is the link to share with viewers, empty if there is no session
+	URL      string
+	Expires  time.Time
+	Duration time.Duration
+	// HasLocation is false if no location of the device is known yet
+	HasLocation bool
+	Latitude    float64
+	Longitude   float64
+	// Error is the reason a session could not be created
+	Error string
+	// LastSeen is the time of the last location of a silent device
+	LastSeen time.Time
+}
+
+// Session describes the Hauk session a notification is about
+type Session struct {
+	URL     string
+	Created time.Time
+	Expires time.Time
+	// LastLocation is the last known location of the device, nil if unknown
+	LastLocation *geo.Point
+}
+
+var defaultTemplates = map[string]TemplateConfig{
+	EventNewSession: {
+		Subject: "Forwarding {{.Name}} to Hauk",
+		Body:    "New session: {{.URL}}",
+	},
+	EventSessionStopped: {
+		Subject: "Stopped forwarding {{.Name}} to Hauk",
+		Body:    "The session has been stopped.",
+	},
+	EventSessionExpired: {
+		Subject: "Session of {{.Name}} expired",
+		Body:    "The session has expired.",
+	},
+	EventSessionFailed: {
+		Subject: "Could not forward {{.Name}} to Hauk",
+		Body:    "Creating a session failed: {{.Error}}",
+	},
+	EventDeviceSilent: {
+		Subject: "{{.Name}} went silent",
+		Body:    "No location has been received since {{.LastSeen.Format \"2006-01-02 15:04\"}}.",
+	},
+}
+
+// defaultMarkdownTemplates replace the defaults for channels rendering the body as markdown
+var defaultMarkdownTemplates = map[string]TemplateConfig{
+	EventNewSession: {
+		Subject: defaultTemplates[EventNewSession].Subject,
+		Body:    "Forwarding **{{.Name}}** to Hauk\r\n\r\nNew session: [hauk link]({{.URL}})",
+	},
+}
+
+// markdownChannels are the channel types rendering the body as markdown
+var markdownChannels = map[string]bool{
+	ChannelGotify: true,
+	ChannelNtfy:   true,
+}
+
+// messageTemplate renders one kind of message
+type messageTemplate struct {
+	subject  *template.Template
+	body     *template.Template
+	htmlBody *htmltemplate.Template
+}
+
+// newTemplates compiles the templates of a channel for each event type, using the defaults for missing ones.
+// Channels rendering markdown get the markdown defaults.
+func newTemplates(configs map[string]TemplateConfig, markdown bool) (map[string]*messageTemplate, error) {
+	templates := make(map[string]*messageTemplate)
+	for kind, defaults := range defaultTemplates {
+		if markdownDefaults, exists := defaultMarkdownTemplates[kind]; exists && markdown {
+			defaults = markdownDefaults
+		}
+		config := configs[kind]
+		subject, err := loadTemplate(config.Subject, config.SubjectFile, defaults.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("Subject template of %s: %w", kind, err)
+		}
+		body, err := loadTemplate(config.Body, config.BodyFile, defaults.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Body template of %s: %w", kind, err)
+		}
+		htmlBody, err := loadTemplate(config.HTMLBody, config.HTMLBodyFile, defaults.HTMLBody)
+		if err != nil {
+			return nil, fmt.Errorf("HTML body template of %s: %w", kind, err)
+		}
+
+		messageTemplate := &messageTemplate{}
+		if messageTemplate.subject, err = template.New("subject").Parse(subject); err != nil {
+			return nil, fmt.Errorf("Subject template of %s: %w", kind, err)
+		}
+		if messageTemplate.body, err = template.New("body").Parse(body); err != nil {
+			return nil, fmt.Errorf("Body template of %s: %w", kind, err)
+		}
+		if htmlBody != "" {
+			if messageTemplate.htmlBody, err = htmltemplate.New("html_body").Parse(htmlBody); err != nil {
+				return nil, fmt.Errorf("HTML body template of %s: %w", kind, err)
+			}
+		}
+		templates[kind] = messageTemplate
+	}
+	for kind := range configs {
+		if _, exists := defaultTemplates[kind]; !exists {
+			return nil, fmt.Errorf("Unknown event type %s", kind)
+		}
+	}
+	return templates, nil
+}
+
+func loadTemplate(inline string, file string, fallback string) (string, error) {
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("Could not read template file %s: %w", file, err)
+		}
+		return string(content), nil
+	}
+	if inline != "" {
+		return inline, nil
+	}
+	return fallback, nil
+}
+
+// executor is implemented by text and HTML templates
+type executor interface {
+	Execute(writer io.Writer, data interface{}) error
+}
+
+// render fills the templates with the data
+func (t *messageTemplate) render(data TemplateData) (Message, error) {
+	var message Message
+	var err error
+	if message.Title, err = execute(t.subject, data); err != nil {
+		return message, err
+	}
+	if message.Text, err = execute(t.body, data); err != nil {
+		return message, err
+	}
+	if t.htmlBody != nil {
+		if message.HTML, err = execute(t.htmlBody, data); err != nil {
+			return message, err
+		}
+	}
+	return message, nil
+}
+
+func execute(template executor, data TemplateData) (string, error) {
+	var buffer bytes.Buffer
+	if err := template.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func newTemplateData(event Event) TemplateData {
+	device, session := event.Device, event.Session
+	data := TemplateData{
+		Name:     device.DisplayName(),
+		Topic:    device.Topic,
+		URL:      session.URL,
+		Expires:  session.Expires,
+		LastSeen: event.LastSeen,
+	}
+	if event.Err != nil {
+		data.Error = event.Err.Error()
+	}
+	if !session.Created.IsZero() && !session.Expires.IsZero() {
+		data.Duration = session.Expires.Sub(session.Created)
+	}
+	if session.LastLocation != nil {
+		data.HasLocation = true
+		data.Latitude = session.LastLocation.Latitude
+		data.Longitude = session.LastLocation.Longitude
+	}
+	return data
+}
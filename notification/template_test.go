@@ -0,0 +1,80 @@
+package notification
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuffnerdstuff/hauk-snitch/geo"
+)
+
+func TestNewTemplates_Defaults(t *testing.T) {
+	// given
+	templates, err := newTemplates(nil, false)
+	assert.NoError(t, err)
+
+	// when
+	message, err := templates[EventNewSession].render(newTemplateData(Event{Device: Device{Topic: "owntracks/dude/phone"}, Session: Session{URL: "URL"}}))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, Message{Title: "Forwarding owntracks/dude/phone to Hauk", Text: "New session: URL"}, message)
+}
+
+func TestNewTemplates_MarkdownDefaults(t *testing.T) {
+	// given
+	templates, err := newTemplates(nil, true)
+	assert.NoError(t, err)
+
+	// when
+	message, err := templates[EventNewSession].render(newTemplateData(Event{Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}, Session: Session{URL: "URL"}}))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, Message{Title: "Forwarding Dude to Hauk", Text: "Forwarding **Dude** to Hauk\r\n\r\nNew session: [hauk link](URL)"}, message)
+}
+
+func TestNewTemplates_Custom(t *testing.T) {
+	// given: German subject, body from file and HTML body
+	bodyFile := filepath.Join(t.TempDir(), "body.txt")
+	assert.NoError(t, ioutil.WriteFile(bodyFile, []byte("{{.Name}} ist bei {{.Latitude}}, {{.Longitude}}. Link gültig für {{.Duration}}: {{.URL}}"), 0600))
+	templates, err := newTemplates(map[string]TemplateConfig{
+		EventNewSession: {
+			Subject:  "{{.Name}} teilt den Standort bis {{.Expires.Format \"15:04\"}}",
+			Body:     "ignored",
+			BodyFile: bodyFile,
+			HTMLBody: "<a href=\"{{.URL}}\">{{.Name}}</a>",
+		},
+	}, false)
+	assert.NoError(t, err)
+	created := time.Date(2021, 4, 12, 16, 0, 0, 0, time.UTC)
+	session := Session{
+		URL:          "https://hauk/?x=1&y=<2>",
+		Created:      created,
+		Expires:      created.Add(time.Hour),
+		LastLocation: &geo.Point{Latitude: 47.5, Longitude: 12.9},
+	}
+
+	// when
+	message, err := templates[EventNewSession].render(newTemplateData(Event{Device: Device{Topic: "owntracks/dude/phone", Name: "Dude"}, Session: session}))
+
+	// then: HTML is escaped, other messages use the defaults
+	assert.NoError(t, err)
+	assert.Equal(t, "Dude teilt den Standort bis 17:00", message.Title)
+	assert.Equal(t, "Dude ist bei 47.5, 12.9. Link gültig für 1h0m0s: https://hauk/?x=1&y=<2>", message.Text)
+	assert.Equal(t, "<a href=\"https://hauk/?x=1&amp;y=%3c2%3e\">Dude</a>", message.HTML)
+	stopped, err := templates[EventSessionStopped].render(TemplateData{Name: "Dude"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Stopped forwarding Dude to Hauk", stopped.Title)
+}
+
+func TestNewTemplates_Invalid(t *testing.T) {
+	_, err := newTemplates(map[string]TemplateConfig{EventNewSession: {Subject: "{{.Name"}}, false)
+	assert.Error(t, err)
+	_, err = newTemplates(map[string]TemplateConfig{"birthday": {Subject: "Happy birthday"}}, false)
+	assert.Error(t, err)
+	_, err = newTemplates(map[string]TemplateConfig{EventNewSession: {BodyFile: "/does/not/exist"}}, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// WebhookConfig holds the configuration of a generic JSON webhook
+type WebhookConfig struct {
+	URL     string            `mapstructure:"url"`
+	Method  string            `mapstructure:"method"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+type webhookChannel struct {
+	config WebhookConfig
+}
+
+// webhookPayload is the JSON sent to the webhook
+type webhookPayload struct {
+	Event string `json:"event"`
+	Topic string `json:"topic"`
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	HTML  string `json:"html,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+func newWebhookChannel(channelConfig ChannelConfig) (Channel, error) {
+	var config WebhookConfig
+	if err := channelConfig.Decode(&config); err != nil {
+		return nil, err
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("Webhook url must be set")
+	}
+	return &webhookChannel{config: config}, nil
+}
+
+// Send sends the message as JSON with the configured headers
+func (t *webhookChannel) Send(ctx context.Context, message Message) error {
+	request, err := newJSONRequest(ctx, t.config.Method, t.config.URL, webhookPayload{
+		Event: message.Event,
+		Topic: message.Device.Topic,
+		Name:  message.Device.DisplayName(),
+		Title: message.Title,
+		Text:  message.Text,
+		HTML:  message.HTML,
+		URL:   message.URL,
+	})
+	if err != nil {
+		return err
+	}
+	for name, value := range t.config.Headers {
+		request.Header.Set(name, value)
+	}
+	return do(request)
+}
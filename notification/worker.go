@@ -0,0 +1,188 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/metrics"
+)
+
+var errClosed = errors.New("Notifier closed")
+
+// delivery is a message on its way to a channel
+type delivery struct {
+	// eventType is the type of the event the message is about
+	eventType string
+	message   Message
+	// attempts is the number of failed attempts so far
+	attempts int
+}
+
+// channelWorker delivers the messages of one channel in the background, retrying failed ones
+type channelWorker struct {
+	name        string
+	channelType string
+	// events the channel is subscribed to, nil means all
+	events    []string
+	channel   Channel
+	templates map[string]*messageTemplate
+	delivery  DeliveryConfig
+
+	queue chan delivery
+	done  chan struct{}
+	// mutex guards closed and retries
+	mutex  sync.Mutex
+	closed bool
+	// retries holds the deliveries waiting for their next attempt
+	retries map[*time.Timer]delivery
+}
+
+func newChannelWorker(config ChannelConfig, channel Channel, templates map[string]*messageTemplate) *channelWorker {
+	deliveryConfig := config.Delivery.withDefaults()
+	worker := &channelWorker{
+		name:        config.Name,
+		channelType: config.Type,
+		events:      config.Events,
+		channel:     channel,
+		templates:   templates,
+		delivery:    deliveryConfig,
+		queue:       make(chan delivery, deliveryConfig.QueueSize),
+		done:        make(chan struct{}),
+		retries:     make(map[*time.Timer]delivery),
+	}
+	go worker.run()
+	return worker
+}
+
+func (t *channelWorker) isSubscribed(eventType string) bool {
+	if t.events == nil {
+		return true
+	}
+	for _, subscribed := range t.events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// recipients returns who of the channel is sent messages about the device.
+// Channels which are not a RecipientChannel have no recipients, they are sent a single message.
+// It returns an error if neither the device nor the channel has recipients, an empty list of the device disables the channel.
+func (t *channelWorker) recipients(device Device) (recipients []string, hasRecipients bool, err error) {
+	channel, hasRecipients := t.channel.(RecipientChannel)
+	if !hasRecipients {
+		return nil, false, nil
+	}
+	if recipients = device.recipientsFor(t.name, t.channelType); recipients != nil {
+		return recipients, true, nil
+	}
+	recipients = channel.Recipients()
+	if len(recipients) == 0 {
+		return nil, true, fmt.Errorf("No recipients configured for %s", device.Topic)
+	}
+	return recipients, true, nil
+}
+
+// enqueue adds the delivery to the queue without blocking, it is given up if the queue is full
+func (t *channelWorker) enqueue(delivery delivery) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		t.deadLetter(delivery, errClosed)
+		return
+	}
+	select {
+	case t.queue <- delivery:
+	default:
+		t.deadLetter(delivery, errors.New("Queue full"))
+	}
+}
+
+func (t *channelWorker) run() {
+	defer close(t.done)
+	for delivery := range t.queue {
+		t.deliver(delivery)
+	}
+}
+
+func (t *channelWorker) deliver(delivery delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.delivery.Timeout)
+	err := t.channel.Send(ctx, delivery.message)
+	cancel()
+	if err == nil {
+		metrics.NotificationsSent.WithLabelValues(t.name, metrics.ResultSuccess).Inc()
+		return
+	}
+
+	metrics.NotificationsSent.WithLabelValues(t.name, metrics.ResultFailure).Inc()
+	delivery.attempts++
+	if delivery.attempts > t.delivery.Retries {
+		t.deadLetter(delivery, err)
+		return
+	}
+	backoff := t.backoff(delivery.attempts)
+	log.Printf("Notification %s: could not send message, retrying in %v: %v", t.name, backoff, err)
+	t.scheduleRetry(delivery, backoff)
+}
+
+// backoff returns the time to wait before the next attempt, doubling with each failed attempt
+func (t *channelWorker) backoff(attempts int) time.Duration {
+	backoff := t.delivery.RetryInitial
+	for i := 1; i < attempts && backoff < t.delivery.RetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > t.delivery.RetryMax {
+		backoff = t.delivery.RetryMax
+	}
+	return backoff
+}
+
+func (t *channelWorker) scheduleRetry(delivery delivery, backoff time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		t.deadLetter(delivery, errClosed)
+		return
+	}
+	// The timer cannot fire before it is stored, as the callback needs the mutex
+	var timer *time.Timer
+	timer = time.AfterFunc(backoff, func() {
+		t.mutex.Lock()
+		delete(t.retries, timer)
+		t.mutex.Unlock()
+		t.enqueue(delivery)
+	})
+	t.retries[timer] = delivery
+}
+
+// deadLetter logs the message which could not be delivered, so it is not lost silently.
+// The message itself is not logged, as it usually contains the share link including the end-to-end password.
+func (t *channelWorker) deadLetter(delivery delivery, err error) {
+	metrics.NotificationsSent.WithLabelValues(t.name, metrics.ResultDropped).Inc()
+	log.Printf("Notification %s: giving up %s message about %s after %d attempts: %v",
+		t.name, delivery.eventType, delivery.message.Device.Topic, delivery.attempts, err)
+}
+
+// close stops accepting messages and waits until the queue has been processed
+func (t *channelWorker) close() {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return
+	}
+	t.closed = true
+	for timer, delivery := range t.retries {
+		if timer.Stop() {
+			t.deadLetter(delivery, errClosed)
+		}
+	}
+	t.retries = make(map[*time.Timer]delivery)
+	close(t.queue)
+	t.mutex.Unlock()
+	<-t.done
+}
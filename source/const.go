@@ -0,0 +1,49 @@
+package source
+
+// TypeLocation is the type of events carrying a location
+const TypeLocation string = "location"
+
+// TypeEnter is the type of events sent when a device entered a region
+const TypeEnter string = "enter"
+
+// TypeLeave is the type of events sent when a device left a region
+const TypeLeave string = "leave"
+
+// FieldLatitude is the location field for the latitude in degrees
+const FieldLatitude string = "latitude"
+
+// FieldLongitude is the location field for the longitude in degrees
+const FieldLongitude string = "longitude"
+
+// FieldAltitude is the location field for the altitude in meters
+const FieldAltitude string = "altitude"
+
+// FieldAccuracy is the location field for the accuracy in meters
+const FieldAccuracy string = "accuracy"
+
+// FieldSpeed is the location field for the speed in meters per second
+const FieldSpeed string = "speed"
+
+// FieldTime is the location field for the time as UNIX epoch in seconds
+const FieldTime string = "time"
+
+// FieldBattery is the location field for the battery level in percent
+const FieldBattery string = "battery"
+
+// FieldCharging is the location field for the charging state, any value but 0 means charging
+const FieldCharging string = "charging"
+
+// FieldAccuracyMode is the location field for the accuracy mode, see AccuracyModeFine and AccuracyModeCoarse
+const FieldAccuracyMode string = "accuracy_mode"
+
+// AccuracyModeFine means the location is precise, e.g. determined via GPS
+const AccuracyModeFine int = 0
+
+// AccuracyModeCoarse means the location is imprecise, e.g. determined via cell towers or WiFi
+const AccuracyModeCoarse int = 1
+
+// ValueTypeFloat is the default type of mapped values
+const ValueTypeFloat string = "float"
+
+// ValueTypeInt is the type of mapped values which are truncated to integers
+const ValueTypeInt string = "int"
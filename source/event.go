@@ -0,0 +1,37 @@
+package source
+
+import "time"
+
+// Event is a source neutral message of a device
+type Event struct {
+	// Topic identifies the device, e.g. owntracks/<user>/<device>
+	Topic string
+	Type  string
+	// Location is set for events of TypeLocation
+	Location Location
+	// Manual is true if the location has been requested by the user
+	Manual bool
+	// Region is set for events of TypeEnter and TypeLeave
+	Region string
+}
+
+// Location is a position of a device.
+// Optional values are nil if the source did not provide them.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// Altitude in meters
+	Altitude *float64
+	// Accuracy in meters
+	Accuracy *float64
+	// Speed in meters per second
+	Speed *float64
+	// Time the location has been recorded, zero if unknown
+	Time time.Time
+	// Battery level in percent
+	Battery *float64
+	// Charging is true while the device is plugged in
+	Charging *bool
+	// AccuracyMode tells whether the location is precise (AccuracyModeFine) or not (AccuracyModeCoarse)
+	AccuracyMode *int
+}
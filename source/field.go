@@ -0,0 +1,113 @@
+package source
+
+import (
+	"fmt"
+	"math"
+)
+
+// FieldConfig configures how a payload key is mapped to a location field
+type FieldConfig struct {
+	// Key of the raw payload, keys of nested objects are separated by dots
+	Key string
+	// Target is the location field or its short name (Hauk parameter)
+	Target string
+	// Unit is an optional named unit conversion, e.g. "kmh->ms"
+	Unit string
+	// Scale and Offset are applied after the unit conversion, Scale defaults to 1
+	Scale  *float64
+	Offset float64
+	// Type is ValueTypeFloat (default) or ValueTypeInt
+	Type string
+}
+
+// fieldShortNames maps the short names of fields, which are the Hauk parameters, to the fields
+var fieldShortNames = map[string]string{
+	"lat":  FieldLatitude,
+	"lon":  FieldLongitude,
+	"alt":  FieldAltitude,
+	"acc":  FieldAccuracy,
+	"spd":  FieldSpeed,
+	"time": FieldTime,
+	"bat":  FieldBattery,
+	"chg":  FieldCharging,
+	"prv":  FieldAccuracyMode,
+}
+
+// unitConversions are the named unit conversions, converting to the units of the location fields
+var unitConversions = map[string]func(value float64) float64{
+	"kmh->ms":   func(value float64) float64 { return value / 3.6 },
+	"mph->ms":   func(value float64) float64 { return value * 0.44704 },
+	"kn->ms":    func(value float64) float64 { return value * 1852 / 3600 },
+	"ft->m":     func(value float64) float64 { return value * 0.3048 },
+	"millis->s": func(value float64) float64 { return value / 1000 },
+}
+
+// NewFieldMapping validates the config and returns the mapping of the field
+func NewFieldMapping(config FieldConfig) (FieldMapping, error) {
+	if config.Key == "" {
+		return FieldMapping{}, fmt.Errorf("Key must not be empty")
+	}
+	field, err := parseField(config.Target)
+	if err != nil {
+		return FieldMapping{}, err
+	}
+
+	var unitConversion func(value float64) float64
+	if config.Unit != "" {
+		var known bool
+		if unitConversion, known = unitConversions[config.Unit]; !known {
+			return FieldMapping{}, fmt.Errorf("Unknown unit conversion %s of key %s", config.Unit, config.Key)
+		}
+	}
+	scale := 1.0
+	if config.Scale != nil {
+		scale = *config.Scale
+	}
+	var isInt bool
+	switch config.Type {
+	case "", ValueTypeFloat:
+	case ValueTypeInt:
+		isInt = true
+	default:
+		return FieldMapping{}, fmt.Errorf("Unknown type %s of key %s, must be %s or %s", config.Type, config.Key, ValueTypeFloat, ValueTypeInt)
+	}
+
+	if unitConversion == nil && scale == 1 && config.Offset == 0 && !isInt {
+		return FieldMapping{Field: field}, nil
+	}
+	return FieldMapping{Field: field, Convert: func(value float64) float64 {
+		if unitConversion != nil {
+			value = unitConversion(value)
+		}
+		value = value*scale + config.Offset
+		if isInt {
+			value = math.Trunc(value)
+		}
+		return value
+	}}, nil
+}
+
+func parseField(target string) (string, error) {
+	if field, isShortName := fieldShortNames[target]; isShortName {
+		return field, nil
+	}
+	for _, field := range fieldShortNames {
+		if field == target {
+			return field, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown target %s", target)
+}
+
+// Validate returns an error if the mapping cannot create locations, because latitude or longitude are not mapped
+func (t KeyMapping) Validate() error {
+	var hasLatitude, hasLongitude bool
+	for _, mapping := range t {
+		hasLatitude = hasLatitude || mapping.Field == FieldLatitude
+		hasLongitude = hasLongitude || mapping.Field == FieldLongitude
+	}
+	if !hasLatitude || !hasLongitude {
+		return fmt.Errorf("Latitude and longitude must be mapped")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFieldMapping_Conversions(t *testing.T) {
+	// given
+	scale := 2.0
+
+	// when
+	plain, plainErr := NewFieldMapping(FieldConfig{Key: "lat", Target: "latitude"})
+	unit, unitErr := NewFieldMapping(FieldConfig{Key: "speed", Target: "spd", Unit: "kmh->ms"})
+	scaled, scaledErr := NewFieldMapping(FieldConfig{Key: "alt", Target: "alt", Unit: "ft->m", Scale: &scale, Offset: 10, Type: ValueTypeInt})
+
+	// then: unit conversion first, then scale and offset, then type cast
+	assert.NoError(t, plainErr)
+	assert.Equal(t, FieldLatitude, plain.Field)
+	assert.Nil(t, plain.Convert)
+	assert.NoError(t, unitErr)
+	assert.Equal(t, FieldSpeed, unit.Field)
+	assert.Equal(t, 10.0, unit.Convert(36))
+	assert.NoError(t, scaledErr)
+	assert.Equal(t, FieldAltitude, scaled.Field)
+	assert.Equal(t, 70.0, scaled.Convert(100))
+}
+
+func TestNewFieldMapping_Invalid(t *testing.T) {
+	for _, config := range []FieldConfig{
+		{Target: "lat"},
+		{Key: "x", Target: "height"},
+		{Key: "x", Target: "alt", Unit: "furlong->m"},
+		{Key: "x", Target: "alt", Type: "string"},
+	} {
+		_, err := NewFieldMapping(config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
+
+func TestKeyMapping_Validate(t *testing.T) {
+	assert.NoError(t, KeyMapping{"a": {Field: FieldLatitude}, "b": {Field: FieldLongitude}}.Validate())
+	assert.Error(t, KeyMapping{"a": {Field: FieldLatitude}}.Validate())
+}
+
+func TestKeyMapping_LocationNested(t *testing.T) {
+	// given: a Tasmota like payload with string values
+	mapping := KeyMapping{"GPS.lat": {Field: FieldLatitude}, "GPS.lon": {Field: FieldLongitude}, "GPS.fix": {Field: FieldCharging}}
+
+	// when
+	location, err := mapping.Location(map[string]interface{}{"GPS": map[string]interface{}{"lat": "47.5", "lon": 12.9, "fix": true}})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 47.5, location.Latitude)
+	assert.Equal(t, 12.9, location.Longitude)
+	assert.True(t, *location.Charging)
+}
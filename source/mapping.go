@@ -0,0 +1,118 @@
+package source
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyMapping maps the keys of a raw payload to location fields.
+// Every source provides a mapping for its payload format.
+// Keys of nested objects are separated by dots, e.g. "GPS.lat".
+type KeyMapping map[string]FieldMapping
+
+// FieldMapping maps a raw value to a location field
+type FieldMapping struct {
+	Field string
+	// Convert converts the raw value to the unit of the field, it is optional.
+	// If it returns NaN, the field is left unset.
+	Convert func(value float64) float64
+}
+
+// Location creates a location from the raw payload.
+// Keys without mapping are ignored, latitude and longitude are mandatory.
+func (t KeyMapping) Location(payload map[string]interface{}) (Location, error) {
+	var location Location
+	var hasLatitude, hasLongitude bool
+	for key, mapping := range t {
+		rawValue, found := lookup(payload, key)
+		if !found {
+			continue
+		}
+		value, ok := toFloat(rawValue)
+		if !ok {
+			return location, fmt.Errorf("Value of %s is not a number: %v", key, rawValue)
+		}
+		if mapping.Convert != nil {
+			value = mapping.Convert(value)
+		}
+		if math.IsNaN(value) {
+			continue
+		}
+		switch mapping.Field {
+		case FieldLatitude:
+			location.Latitude = value
+			hasLatitude = true
+		case FieldLongitude:
+			location.Longitude = value
+			hasLongitude = true
+		case FieldAltitude:
+			location.Altitude = &value
+		case FieldAccuracy:
+			location.Accuracy = &value
+		case FieldSpeed:
+			location.Speed = &value
+		case FieldTime:
+			seconds, fraction := math.Modf(value)
+			location.Time = time.Unix(int64(seconds), int64(fraction*1e9))
+		case FieldBattery:
+			location.Battery = &value
+		case FieldCharging:
+			charging := value != 0
+			location.Charging = &charging
+		case FieldAccuracyMode:
+			accuracyMode := int(value)
+			location.AccuracyMode = &accuracyMode
+		default:
+			return location, fmt.Errorf("Unknown location field %s", mapping.Field)
+		}
+	}
+	if !hasLatitude || !hasLongitude {
+		return location, fmt.Errorf("Location has no latitude or longitude")
+	}
+	return location, nil
+}
+
+// lookup returns the value of the key, descending into nested objects for keys containing dots
+func lookup(payload map[string]interface{}, key string) (interface{}, bool) {
+	if value, found := payload[key]; found {
+		return value, true
+	}
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	nested, isObject := payload[parts[0]].(map[string]interface{})
+	if !isObject {
+		return nil, false
+	}
+	return lookup(nested, parts[1])
+}
+
+// toFloat converts a value of a decoded payload to float64.
+// Numeric strings are parsed, booleans are converted to 1 and 0.
+func toFloat(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case string:
+		floatValue, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return floatValue, err == nil
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	}
+	return 0, false
+}
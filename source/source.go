@@ -0,0 +1,29 @@
+package source
+
+import "sync"
+
+// Source delivers events of devices, e.g. received via MQTT or HTTP
+type Source interface {
+	// Events returns the channel of events, which is closed once the source is closed
+	Events() <-chan Event
+}
+
+// Merge fans in the events of all sources into one channel, which is closed once all of them are closed
+func Merge(sources ...Source) <-chan Event {
+	merged := make(chan Event)
+	var waitGroup sync.WaitGroup
+	for _, source := range sources {
+		waitGroup.Add(1)
+		go func(events <-chan Event) {
+			defer waitGroup.Done()
+			for event := range events {
+				merged <- event
+			}
+		}(source.Events())
+	}
+	go func() {
+		waitGroup.Wait()
+		close(merged)
+	}()
+	return merged
+}
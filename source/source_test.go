@@ -0,0 +1,67 @@
+package source
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type channelSource chan Event
+
+func (t channelSource) Events() <-chan Event {
+	return t
+}
+
+func TestKeyMapping_Location(t *testing.T) {
+	// given: a mapping in feet and milliseconds
+	mapping := KeyMapping{
+		"la": {Field: FieldLatitude},
+		"lo": {Field: FieldLongitude},
+		"ft": {Field: FieldAltitude, Convert: func(value float64) float64 { return value * 0.3048 }},
+		"ms": {Field: FieldTime, Convert: func(value float64) float64 { return value / 1000 }},
+	}
+
+	// when
+	location, err := mapping.Location(map[string]interface{}{"la": 47.5, "lo": 12, "ft": 1000, "ms": 1500.0, "other": "ignored"})
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 47.5, location.Latitude)
+	assert.Equal(t, 12.0, location.Longitude)
+	assert.InDelta(t, 304.8, *location.Altitude, 0.001)
+	assert.Nil(t, location.Accuracy)
+	assert.Equal(t, time.Unix(1, 500000000), location.Time)
+}
+
+func TestKeyMapping_LocationInvalid(t *testing.T) {
+	// given
+	mapping := KeyMapping{"la": {Field: FieldLatitude}, "lo": {Field: FieldLongitude}}
+
+	// when
+	_, missingErr := mapping.Location(map[string]interface{}{"la": 47.5})
+	_, notNumberErr := mapping.Location(map[string]interface{}{"la": 47.5, "lo": "east"})
+
+	// then
+	assert.Error(t, missingErr)
+	assert.Error(t, notNumberErr)
+}
+
+func TestMerge(t *testing.T) {
+	// given: two sources
+	first := make(channelSource, 1)
+	second := make(channelSource, 1)
+	first <- Event{Topic: "first"}
+	second <- Event{Topic: "second"}
+	close(first)
+	close(second)
+
+	// when
+	var topics []string
+	for event := range Merge(first, second) {
+		topics = append(topics, event.Topic)
+	}
+
+	// then: all events are delivered, channel is closed after both sources
+	assert.ElementsMatch(t, []string{"first", "second"}, topics)
+}
@@ -0,0 +1,7 @@
+package store
+
+// Config holds the configuration of the session store
+type Config struct {
+	Type string
+	Path string
+}
@@ -0,0 +1,7 @@
+package store
+
+// TypeMemory is the store type keeping sessions in memory only
+const TypeMemory string = "memory"
+
+// TypeFile is the store type persisting sessions to a JSON file
+const TypeFile string = "file"
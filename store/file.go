@@ -0,0 +1,101 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type fileStore struct {
+	mutex   sync.RWMutex
+	path    string
+	entries map[string]Entry
+}
+
+// NewFile creates a store which persists sessions to a JSON file at the given path.
+// Existing sessions are loaded from the file, expired ones are pruned.
+func NewFile(path string) (Store, error) {
+	store := &fileStore{path: path, entries: make(map[string]Entry)}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (t *fileStore) Get(topic string) (Entry, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	entry, exists := t.entries[topic]
+	return entry, exists
+}
+
+func (t *fileStore) Put(topic string, entry Entry) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries[topic] = entry
+	return t.save()
+}
+
+func (t *fileStore) Delete(topic string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.entries, topic)
+	return t.save()
+}
+
+func (t *fileStore) All() map[string]Entry {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return copyEntries(t.entries)
+}
+
+func (t *fileStore) load() error {
+	data, err := ioutil.ReadFile(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Could not read session store %s: %w", t.path, err)
+	}
+
+	if err = json.Unmarshal(data, &t.entries); err != nil {
+		return fmt.Errorf("Could not parse session store %s: %w", t.path, err)
+	}
+
+	// Prune sessions which expired while we were gone
+	now := time.Now()
+	for topic, entry := range t.entries {
+		if entry.IsExpired(now) {
+			log.Printf("Pruning expired session for %s: %v", topic, entry.Session)
+			delete(t.entries, topic)
+		} else {
+			log.Printf("Restored session for %s: %v", topic, entry.Session)
+		}
+	}
+	return t.save()
+}
+
+func (t *fileStore) save() error {
+	data, err := json.MarshalIndent(t.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Could not serialize session store: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return fmt.Errorf("Could not create session store directory: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store behind
+	tmpPath := t.path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("Could not write session store %s: %w", tmpPath, err)
+	}
+	if err = os.Rename(tmpPath, t.path); err != nil {
+		return fmt.Errorf("Could not write session store %s: %w", t.path, err)
+	}
+	return nil
+}
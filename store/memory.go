@@ -0,0 +1,40 @@
+package store
+
+import "sync"
+
+type memoryStore struct {
+	mutex   sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemory creates a store which forgets all sessions on restart
+func NewMemory() Store {
+	return &memoryStore{entries: make(map[string]Entry)}
+}
+
+func (t *memoryStore) Get(topic string) (Entry, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	entry, exists := t.entries[topic]
+	return entry, exists
+}
+
+func (t *memoryStore) Put(topic string, entry Entry) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries[topic] = entry
+	return nil
+}
+
+func (t *memoryStore) Delete(topic string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.entries, topic)
+	return nil
+}
+
+func (t *memoryStore) All() map[string]Entry {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return copyEntries(t.entries)
+}
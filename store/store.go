@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+)
+
+// Entry is a hauk session stored for a topic
+type Entry struct {
+	Session hauk.Session
+	Created time.Time
+	Expires time.Time
+}
+
+// IsExpired returns true if the session has expired at the given time
+func (t Entry) IsExpired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// Store keeps track of the active hauk session of each topic
+type Store interface {
+	Get(topic string) (Entry, bool)
+	Put(topic string, entry Entry) error
+	Delete(topic string) error
+	All() map[string]Entry
+}
+
+// New creates the store configured by the given config
+func New(config Config) (Store, error) {
+	switch config.Type {
+	case TypeMemory, "":
+		return NewMemory(), nil
+	case TypeFile:
+		return NewFile(config.Path)
+	default:
+		return nil, fmt.Errorf("Unknown store type %s", config.Type)
+	}
+}
+
+func copyEntries(entries map[string]Entry) map[string]Entry {
+	entriesCopy := make(map[string]Entry, len(entries))
+	for topic, entry := range entries {
+		entriesCopy[topic] = entry
+	}
+	return entriesCopy
+}
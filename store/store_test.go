@@ -0,0 +1,64 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tuffnerdstuff/hauk-snitch/hauk"
+)
+
+func TestFileStore_PersistsSessions(t *testing.T) {
+	// given: file store with an active session
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	fileStore, err := NewFile(path)
+	assert.NoError(t, err)
+	entry := Entry{Session: hauk.Session{SID: "sid", URL: "url"}, Created: time.Now(), Expires: time.Now().Add(time.Hour)}
+	assert.NoError(t, fileStore.Put("whatevs", entry))
+
+	// when: store is reloaded
+	reloadedStore, err := NewFile(path)
+	assert.NoError(t, err)
+
+	// then: session is restored
+	reloadedEntry, exists := reloadedStore.Get("whatevs")
+	assert.True(t, exists)
+	assert.Equal(t, entry.Session, reloadedEntry.Session)
+	assert.True(t, entry.Expires.Equal(reloadedEntry.Expires))
+}
+
+func TestFileStore_PrunesExpiredSessions(t *testing.T) {
+	// given: file store with an expired and an active session
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	fileStore, err := NewFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, fileStore.Put("expired", Entry{Session: hauk.Session{SID: "old"}, Expires: time.Now().Add(-time.Minute)}))
+	assert.NoError(t, fileStore.Put("active", Entry{Session: hauk.Session{SID: "new"}, Expires: time.Now().Add(time.Hour)}))
+
+	// when: store is reloaded
+	reloadedStore, err := NewFile(path)
+	assert.NoError(t, err)
+
+	// then: only the active session is left
+	entries := reloadedStore.All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "new", entries["active"].Session.SID)
+}
+
+func TestFileStore_Delete(t *testing.T) {
+	// given: file store with a session
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	fileStore, err := NewFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, fileStore.Put("whatevs", Entry{Session: hauk.Session{SID: "sid"}, Expires: time.Now().Add(time.Hour)}))
+
+	// when
+	assert.NoError(t, fileStore.Delete("whatevs"))
+	reloadedStore, err := NewFile(path)
+	assert.NoError(t, err)
+
+	// then
+	_, exists := reloadedStore.Get("whatevs")
+	assert.False(t, exists)
+}